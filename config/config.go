@@ -0,0 +1,157 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// System wide configuration file.
+const SystemFile = "/etc/pack.conf"
+
+// Pack configuration with default parameters and named registry profiles.
+type Config struct {
+	Defaults   Defaults            `toml:"defaults"`
+	Registries map[string]Registry `toml:"registries"`
+}
+
+// Default values for command line options.
+type Defaults struct {
+	// Cache directory with package files.
+	Dir string `toml:"dir"`
+	// Distribution used in registries.
+	Distro string `toml:"distro"`
+	// Architecture used in registries.
+	Arch string `toml:"arch"`
+	// Use HTTP instead of HTTPS.
+	Insecure bool `toml:"insecure"`
+	// API rootpath of registries.
+	Endpoint string `toml:"endpoint"`
+	// Fingerprint of signing key.
+	Key string `toml:"key"`
+	// Timeout for connecting to registry and waiting for it's response.
+	Timeout time.Duration `toml:"timeout"`
+	// Amount of retries for failed uploads.
+	Retries *int `toml:"retries"`
+	// Amount of packages uploaded simultaneously.
+	Jobs int `toml:"jobs"`
+}
+
+// Named registry profile, profile name can be used instead of registry and
+// owner in command targets. Profiles can contain credentials, in that case
+// configuration file should not be accessible by other users. Credentials
+// in system wide configuration file are ignored.
+type Registry struct {
+	Host     string `toml:"host"`
+	Owner    string `toml:"owner"`
+	Protocol string `toml:"protocol"`
+	Endpoint string `toml:"endpoint"`
+	Distro   string `toml:"distro"`
+	Key      string `toml:"key"`
+	KeyURL   string `toml:"keyurl"`
+	NoKey    bool   `toml:"nokey"`
+	Credentials
+}
+
+// Get path to user configuration file, XDG_CONFIG_HOME is respected.
+func UserFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ``, err
+	}
+	return path.Join(dir, "pack", "config.toml"), nil
+}
+
+// Load system and user configuration files. Values from user configuration
+// override system ones, missing files are skipped.
+func Load() (*Config, error) {
+	files := []string{SystemFile}
+	userfile, err := UserFile()
+	if err == nil {
+		files = append(files, userfile)
+	}
+	return LoadFiles(files...)
+}
+
+// Load provided configuration files in order, each next file overrides
+// values from previous ones. Missing files are skipped.
+func LoadFiles(files ...string) (*Config, error) {
+	c := &Config{Registries: map[string]Registry{}}
+	for _, file := range files {
+		err := decodeFile(c, file, file == SystemFile)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	for name, r := range c.Registries {
+		if r.Host == `` {
+			return nil, fmt.Errorf("registry profile %s has no host", name)
+		}
+		if r.Protocol != `` && r.Protocol != "http" && r.Protocol != "https" {
+			return nil, fmt.Errorf("registry profile %s has unknown protocol %s", name, r.Protocol)
+		}
+	}
+	return c, nil
+}
+
+// Decode configuration file into provided config. System wide file is
+// readable by all users, so credentials in it's registry profiles are
+// ignored, other files with credentials should be private.
+func decodeFile(c *Config, file string, system bool) error {
+	md, err := toml.DecodeFile(file, c)
+	if errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read config %s: %w", file, err)
+	}
+	for name, r := range c.Registries {
+		if !md.IsDefined("registries", name, "token") &&
+			!md.IsDefined("registries", name, "password") {
+			continue
+		}
+		if system {
+			r.Credentials = Credentials{}
+			c.Registries[name] = r
+			continue
+		}
+		err = checkPrivate(file)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Expand target starting with registry profile name (profile/package) to
+// registry/owner/package. Returns target unchanged and nil profile if it
+// does not reference any profile.
+func (c *Config) Expand(target string) (string, *Registry) {
+	name, rest, ok := strings.Cut(target, "/")
+	if !ok {
+		return target, nil
+	}
+	r, ok := c.Registries[name]
+	if !ok {
+		return target, nil
+	}
+	return path.Join(r.Host, r.Owner, rest), &r
+}
+
+// Check wether registry should be accessed over HTTP.
+func (r *Registry) Insecure() bool {
+	return r.Protocol == "http"
+}
@@ -0,0 +1,182 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package config
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Write configuration file with provided permissions to temporary directory.
+func writeFile(t *testing.T, name, content string, perm os.FileMode) string {
+	t.Helper()
+	file := path.Join(t.TempDir(), name)
+	err := os.WriteFile(file, []byte(content), perm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chmod(file, perm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoadFiles(t *testing.T) {
+	system := writeFile(t, "pack.conf", `
+[defaults]
+distro = "archlinux"
+endpoint = "/api/packages/arch"
+timeout = "10s"
+jobs = 2
+
+[registries.fmnx]
+host = "fmnx.su"
+owner = "core"
+`, 0o644)
+	user := writeFile(t, "config.toml", `
+[defaults]
+jobs = 8
+retries = 0
+
+[registries.work]
+host = "git.example.com"
+owner = "team"
+protocol = "http"
+token = "secret"
+`, 0o600)
+	missing := path.Join(t.TempDir(), "missing.toml")
+
+	c, err := LoadFiles(system, missing, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retries := 0
+	want := &Config{
+		Defaults: Defaults{
+			Distro:   "archlinux",
+			Endpoint: "/api/packages/arch",
+			Timeout:  10 * time.Second,
+			Jobs:     8,
+			Retries:  &retries,
+		},
+		Registries: map[string]Registry{
+			"fmnx": {Host: "fmnx.su", Owner: "core"},
+			"work": {
+				Host:        "git.example.com",
+				Owner:       "team",
+				Protocol:    "http",
+				Credentials: Credentials{Token: "secret"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("config:\n  got:  %+v\n  want: %+v", c, want)
+	}
+}
+
+func TestLoadFilesErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		perm    os.FileMode
+		err     string
+	}{
+		{
+			name:    "syntax",
+			content: "[defaults\n",
+			perm:    0o644,
+			err:     "unable to read config",
+		},
+		{
+			name:    "no host",
+			content: "[registries.fmnx]\nowner = \"core\"\n",
+			perm:    0o644,
+			err:     "registry profile fmnx has no host",
+		},
+		{
+			name:    "protocol",
+			content: "[registries.fmnx]\nhost = \"fmnx.su\"\nprotocol = \"ftp\"\n",
+			perm:    0o644,
+			err:     "registry profile fmnx has unknown protocol ftp",
+		},
+		{
+			name:    "public token",
+			content: "[registries.fmnx]\nhost = \"fmnx.su\"\ntoken = \"secret\"\n",
+			perm:    0o644,
+			err:     "should not be accessible by other users",
+		},
+		{
+			name:    "public password",
+			content: "[registries.fmnx]\nhost = \"fmnx.su\"\nuser = \"john\"\npassword = \"secret\"\n",
+			perm:    0o640,
+			err:     "should not be accessible by other users",
+		},
+	}
+	for _, c := range cases {
+		file := writeFile(t, "config.toml", c.content, c.perm)
+		_, err := LoadFiles(file)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: expected error %q, got %v", c.name, c.err, err)
+		}
+	}
+}
+
+// Credentials from system wide file are skipped instead of failing every
+// command for users, who can not change it's permissions.
+func TestDecodeSystemFile(t *testing.T) {
+	file := writeFile(t, "pack.conf", `
+[registries.fmnx]
+host = "fmnx.su"
+owner = "core"
+user = "john"
+password = "secret"
+`, 0o644)
+	c := &Config{Registries: map[string]Registry{}}
+	err := decodeFile(c, file, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Registry{Host: "fmnx.su", Owner: "core"}
+	if !reflect.DeepEqual(c.Registries["fmnx"], want) {
+		t.Errorf("got %+v, want %+v", c.Registries["fmnx"], want)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	c := &Config{Registries: map[string]Registry{
+		"fmnx":  {Host: "fmnx.su", Owner: "core"},
+		"local": {Host: "localhost:4572"},
+	}}
+	cases := []struct {
+		target  string
+		want    string
+		profile string
+	}{
+		{"fmnx/pack", "fmnx.su/core/pack", "fmnx"},
+		{"fmnx/", "fmnx.su/core", "fmnx"},
+		{"local/john/pack", "localhost:4572/john/pack", "local"},
+		{"fmnx.su/core/pack", "fmnx.su/core/pack", ``},
+		{"fmnx", "fmnx", ``},
+		{"pack", "pack", ``},
+	}
+	for _, tc := range cases {
+		got, r := c.Expand(tc.target)
+		if got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.target, got, tc.want)
+		}
+		switch {
+		case tc.profile == `` && r != nil:
+			t.Errorf("%s: unexpected profile %+v", tc.target, r)
+		case tc.profile != `` && (r == nil || *r != c.Registries[tc.profile]):
+			t.Errorf("%s: expected profile %s, got %+v", tc.target, tc.profile, r)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Prefixes of environment variables with credentials. Variables are bound to
+// single registry host, which is appended to prefix, see hostEnv.
+const (
+	tokenEnv    = "PACK_TOKEN_"
+	userEnv     = "PACK_USER_"
+	passwordEnv = "PACK_PASSWORD_"
+)
+
+// Credentials used to access private registry. Token is sent as bearer
+// token, user and password with basic auth.
+type Credentials struct {
+	Token    string `toml:"token"`
+	User     string `toml:"user"`
+	Password string `toml:"password"`
+}
+
+// Check wether credentials are not provided.
+func (c Credentials) Empty() bool {
+	return c.Token == `` && c.User == `` && c.Password == ``
+}
+
+// Add authorization header to request.
+func (c Credentials) Apply(req *http.Request) {
+	switch {
+	case c.Token != ``:
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	case c.User != ``:
+		req.SetBasicAuth(c.User, c.Password)
+	}
+}
+
+// Get suffix of environment variables with credentials for registry host.
+// Host is uppercased and all characters except letters and digits are
+// replaced with underscores, so that for git.example.com token is taken from
+// PACK_TOKEN_GIT_EXAMPLE_COM.
+func hostEnv(host string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, host)
+}
+
+// Get path to credentials file, XDG_CONFIG_HOME is respected.
+func CredentialsFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ``, err
+	}
+	return path.Join(dir, "pack", "credentials.toml"), nil
+}
+
+// Find credentials for registry host. Environment variables for that host
+// take precedence over credentials file, and credentials file over registry
+// profiles.
+func LookupCredentials(host string) (Credentials, error) {
+	suffix := hostEnv(host)
+	env := Credentials{
+		Token:    os.Getenv(tokenEnv + suffix),
+		User:     os.Getenv(userEnv + suffix),
+		Password: os.Getenv(passwordEnv + suffix),
+	}
+	if !env.Empty() {
+		return env, nil
+	}
+
+	file, err := CredentialsFile()
+	if err == nil {
+		creds, err := readCredentials(file)
+		if err != nil {
+			return Credentials{}, err
+		}
+		if c, ok := creds[host]; ok {
+			return c, nil
+		}
+	}
+
+	c, err := Load()
+	if err != nil {
+		return Credentials{}, err
+	}
+	for _, r := range c.Registries {
+		if r.Host == host && !r.Credentials.Empty() {
+			return r.Credentials, nil
+		}
+	}
+	return Credentials{}, nil
+}
+
+// Read credentials file, where credentials are stored in tables named after
+// registry host. File should not be accessible by other users.
+func readCredentials(file string) (map[string]Credentials, error) {
+	creds := map[string]Credentials{}
+	err := checkPrivate(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return creds, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	_, err = toml.DecodeFile(file, &creds)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read credentials %s: %w", file, err)
+	}
+	return creds, nil
+}
+
+// Ensure that file with secrets can be accessed only by it's owner.
+func checkPrivate(file string) error {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	if fi.Mode().Perm()&0o077 != 0 {
+		return fmt.Errorf(
+			"%s contains credentials and should not be accessible by other users, run: chmod 600 %s",
+			file, file,
+		)
+	}
+	return nil
+}
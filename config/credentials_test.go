@@ -0,0 +1,162 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package config
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestHostEnv(t *testing.T) {
+	cases := map[string]string{
+		"fmnx.su":              "FMNX_SU",
+		"git.example.com:3000": "GIT_EXAMPLE_COM_3000",
+		"Localhost":            "LOCALHOST",
+		"[::1]:8080":           "___1__8080",
+	}
+	for host, want := range cases {
+		if got := hostEnv(host); got != want {
+			t.Errorf("hostEnv(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
+
+// Write credentials file and user configuration to temporary config
+// directory, empty contents are not written.
+func configHome(t *testing.T, credentials, config string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	err := os.MkdirAll(path.Join(home, "pack"), 0o700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credentials != `` {
+		err = os.WriteFile(path.Join(home, "pack", "credentials.toml"), []byte(credentials), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if config != `` {
+		err = os.WriteFile(path.Join(home, "pack", "config.toml"), []byte(config), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLookupCredentials(t *testing.T) {
+	configHome(t, `
+["fmnx.su"]
+token = "file-token"
+
+["git.example.com"]
+user = "john"
+password = "file-password"
+`, `
+[registries.fmnx]
+host = "fmnx.su"
+token = "profile-token"
+
+[registries.local]
+host = "localhost:4572"
+token = "profile-local"
+`)
+	t.Setenv("PACK_USER_GIT_EXAMPLE_COM", "jane")
+	t.Setenv("PACK_PASSWORD_GIT_EXAMPLE_COM", "env-password")
+	t.Setenv("PACK_TOKEN_FMNX_SU", ``)
+
+	cases := []struct {
+		host string
+		want Credentials
+	}{
+		{"git.example.com", Credentials{User: "jane", Password: "env-password"}},
+		{"fmnx.su", Credentials{Token: "file-token"}},
+		{"localhost:4572", Credentials{Token: "profile-local"}},
+		{"example.org", Credentials{}},
+	}
+	for _, c := range cases {
+		got, err := LookupCredentials(c.host)
+		if err != nil {
+			t.Fatalf("%s: %v", c.host, err)
+		}
+		if got != c.want {
+			t.Errorf("%s: got %+v, want %+v", c.host, got, c.want)
+		}
+	}
+
+	t.Setenv("PACK_TOKEN_FMNX_SU", "env-token")
+	got, err := LookupCredentials("fmnx.su")
+	if err != nil || got != (Credentials{Token: "env-token"}) {
+		t.Errorf("environment should take precedence over file, got %+v %v", got, err)
+	}
+}
+
+func TestLookupCredentialsPublicFile(t *testing.T) {
+	configHome(t, "[\"fmnx.su\"]\ntoken = \"secret\"\n", ``)
+	file, err := CredentialsFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chmod(file, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LookupCredentials("fmnx.su")
+	if err == nil || !strings.Contains(err.Error(), "chmod 600 "+file) {
+		t.Errorf("expected permission error, got %v", err)
+	}
+}
+
+func TestCheckPrivate(t *testing.T) {
+	cases := []struct {
+		perm os.FileMode
+		ok   bool
+	}{
+		{0o600, true},
+		{0o400, true},
+		{0o700, true},
+		{0o640, false},
+		{0o604, false},
+		{0o666, false},
+	}
+	for _, c := range cases {
+		file := writeFile(t, "credentials.toml", ``, c.perm)
+		err := checkPrivate(file)
+		if (err == nil) != c.ok {
+			t.Errorf("%o: got %v", c.perm, err)
+		}
+	}
+	err := checkPrivate(path.Join(t.TempDir(), "missing.toml"))
+	if !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	cases := []struct {
+		creds Credentials
+		want  string
+	}{
+		{Credentials{}, ``},
+		{Credentials{Token: "abc"}, "Bearer abc"},
+		{Credentials{User: "john", Password: "pw"}, "Basic am9objpwdw=="},
+		{Credentials{Token: "abc", User: "john"}, "Bearer abc"},
+	}
+	for _, c := range cases {
+		req, err := http.NewRequest(http.MethodGet, "https://fmnx.su", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.creds.Apply(req)
+		if got := req.Header.Get("Authorization"); got != c.want {
+			t.Errorf("%+v: got %q, want %q", c.creds, got, c.want)
+		}
+	}
+}
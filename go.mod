@@ -3,14 +3,19 @@ module fmnx.su/core/pack
 go 1.20
 
 require (
+	github.com/BurntSushi/toml v1.3.2
+	github.com/ProtonMail/go-crypto v1.1.6
 	github.com/fatih/color v1.15.0
 	github.com/jessevdk/go-flags v1.5.0
+	github.com/klauspost/compress v1.16.5
 	github.com/mitchellh/ioprogress v0.0.0-20180201004757-6a23b12fa88e
-	golang.org/x/term v0.8.0
+	golang.org/x/term v0.15.0
 )
 
 require (
+	github.com/cloudflare/circl v1.3.7 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.19 // indirect
-	golang.org/x/sys v0.8.0 // indirect
+	golang.org/x/crypto v0.17.0 // indirect
+	golang.org/x/sys v0.16.0 // indirect
 )
@@ -6,10 +6,16 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
+	"fmnx.su/core/pack/config"
 	"fmnx.su/core/pack/msgs"
 	"fmnx.su/core/pack/pack"
 	"fmnx.su/core/pack/pacman"
@@ -17,35 +23,43 @@ import (
 )
 
 var opts struct {
-	Help    bool `long:"help" short:"h"`
-	Version bool `long:"version" short:"v"`
+	Help    bool   `long:"help" short:"h"`
+	Version bool   `long:"version" short:"v"`
+	JSON    bool   `long:"json"`
+	DryRun  bool   `long:"dry-run"`
+	Key     string `long:"key"`
 
 	// Root options.
-	Query  bool `short:"Q" long:"query"`
-	Remove bool `short:"R" long:"remove"`
-	Sync   bool `short:"S" long:"sync"`
-	Push   bool `short:"P" long:"push"`
-	Build  bool `short:"B" long:"build"`
-	Util   bool `short:"U" long:"util"`
+	Query    bool `short:"Q" long:"query"`
+	Remove   bool `short:"R" long:"remove"`
+	Sync     bool `short:"S" long:"sync"`
+	Push     bool `short:"P" long:"push"`
+	Build    bool `short:"B" long:"build"`
+	Util     bool `short:"U" long:"util"`
+	Open     bool `short:"O" long:"open"`
+	Database bool `short:"D" long:"database"`
 
 	// Sync options.
 	Quick   bool   `short:"q" long:"quick"`
 	Refresh []bool `short:"y" long:"refresh"`
 	Upgrade []bool `short:"u" long:"upgrade"`
 	Force   bool   `short:"f" long:"force"`
+	KeyURL  string `long:"keyurl"`
+	NoKey   bool   `long:"nokey"`
+	Pin     bool   `long:"pin"`
 
 	// Push options.
-	Dir      string `short:"d" long:"dir" default:"/var/cache/pacman/pkg"`
+	Dir      string `short:"d" long:"dir"`
 	Insecure bool   `short:"w" long:"insecure"`
-	Endpoint string `long:"endpoint" default:"/api/packages/arch"`
-	Distro   string `long:"distro" default:"archlinux"`
+	Endpoint string `long:"endpoint"`
+	Distro   string `long:"distro"`
 
 	// Remove options.
 	Confirm     bool   `short:"c" long:"confirm"`
 	Norecursive bool   `short:"a" long:"norecursive"`
 	Nocfgs      bool   `short:"j" long:"nocfgs"`
 	Cascade     bool   `long:"cascade"`
-	Arch        string `long:"architecture" default:"x86_64"`
+	Arch        string `long:"architecture"`
 
 	// Query options.
 	Info     []bool `short:"i" long:"info"`
@@ -53,34 +67,81 @@ var opts struct {
 	Outdated bool   `short:"o" long:"outdated"`
 
 	// Build options.
-	Syncbuild bool `short:"s" long:"syncbuild"`
-	Rmdeps    bool `short:"r" long:"rmdeps"`
-	Garbage   bool `short:"g" long:"garbage"`
+	Syncbuild bool   `short:"s" long:"syncbuild"`
+	Rmdeps    bool   `short:"r" long:"rmdeps"`
+	Garbage   bool   `short:"g" long:"garbage"`
+	Registry  string `long:"registry"`
+
+	// Upload options.
+	Timeout time.Duration `long:"timeout"`
+	Retries int           `long:"retries"`
+	Jobs    int           `long:"jobs"`
 
 	// Util options.
-	Gen     bool `long:"gen"`
-	Armor   bool `long:"armor"`
-	Recv    bool `long:"recv"`
-	Setpkgr bool `long:"setpkgr"`
-	Flutter bool `long:"flutter"`
-	Gocli   bool `long:"gocli"`
+	Gen         bool `long:"gen"`
+	Armor       bool `long:"armor"`
+	Recv        bool `long:"recv"`
+	Setpkgr     bool `long:"setpkgr"`
+	Flutter     bool `long:"flutter"`
+	Gocli       bool `long:"gocli"`
+	RestoreConf bool `long:"restore-conf"`
+
+	// Database options.
+	Add    bool `long:"add"`
+	Delete bool `long:"delete"`
+	Prune  bool `long:"prune"`
+
+	// Open options.
+	Name    string `long:"name"`
+	Port    string `short:"p" long:"port" default:"8080"`
+	Storage string `long:"storage" default:"/var/lib/pack"`
+	Cert    string `long:"cert"`
+	Certkey string `long:"certkey"`
+	Gpgdir  string `long:"gpgdir"`
 }
 
 func main() {
 	err := run()
 	if err != nil {
-		if !strings.Contains(err.Error(), "exit status 1") {
+		code := 1
+		var perr *pacman.Error
+		if errors.As(err, &perr) && perr.ExitCode > 0 {
+			code = perr.ExitCode
+		}
+		if msgs.JSON {
+			msgs.Emit(os.Stdout, msgs.Event{
+				Status: msgs.StatusError,
+				Error:  err.Error(),
+			})
+			os.Exit(code)
+		}
+		// Error output of pacman and makepkg is already shown to user.
+		if perr == nil || !perr.Printed {
 			fmt.Println(msgs.Err + err.Error())
 		}
-		os.Exit(1)
+		os.Exit(code)
 	}
 }
 
 func run() error {
-	_, err := flags.NewParser(&opts, flags.None).Parse()
+	parser := flags.NewParser(&opts, flags.None)
+	_, err := parser.Parse()
 	if err != nil {
 		return err
 	}
+	msgs.JSON = opts.JSON
+
+	targets, err := configure(parser)
+	if err != nil {
+		return err
+	}
+	if opts.DryRun {
+		pack.DryRun(os.Stdout)
+	}
+
+	// Interruption cancels running operation, so that it could clean up.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	switch {
 	case opts.Sync && opts.Help:
@@ -88,12 +149,18 @@ func run() error {
 		return nil
 
 	case opts.Sync:
-		return pack.Sync(args(), pack.SyncParameters{
+		return pack.SyncContext(ctx, targets, pack.SyncParameters{
 			Quick:    opts.Quick,
 			Refresh:  opts.Refresh,
 			Upgrade:  opts.Upgrade,
 			Force:    opts.Force,
 			Insecure: opts.Insecure,
+			Endpoint: opts.Endpoint,
+			Distro:   opts.Distro,
+			Arch:     opts.Arch,
+			KeyURL:   opts.KeyURL,
+			NoKey:    opts.NoKey,
+			Pin:      opts.Pin,
 			Stdout:   os.Stdout,
 			Stderr:   os.Stderr,
 			Stdin:    os.Stdin,
@@ -103,15 +170,19 @@ func run() error {
 		fmt.Println(msgs.PushHelp)
 		return nil
 
-	// TODO: when multiple architectures found in cache push them all.
 	case opts.Push:
-		return pack.Push(args(), pack.PushParameters{
+		return pack.PushContext(ctx, targets, pack.PushParameters{
 			Stdout:    os.Stdout,
 			Stderr:    os.Stderr,
 			Stdin:     os.Stdin,
 			Directory: opts.Dir,
 			Insecure:  opts.Insecure,
 			Distro:    opts.Distro,
+			Endpoint:  opts.Endpoint,
+			Key:       opts.Key,
+			Timeout:   opts.Timeout,
+			Retries:   opts.Retries,
+			Jobs:      opts.Jobs,
 		})
 
 	case opts.Remove && opts.Help:
@@ -119,7 +190,7 @@ func run() error {
 		return nil
 
 	case opts.Remove:
-		return pack.Remove(args(), pack.RemoveParameters{
+		return pack.RemoveContext(ctx, targets, pack.RemoveParameters{
 			Stdout:      os.Stdout,
 			Stderr:      os.Stderr,
 			Stdin:       os.Stdin,
@@ -130,6 +201,8 @@ func run() error {
 			Distro:      opts.Distro,
 			Insecure:    opts.Insecure,
 			Arch:        opts.Arch,
+			Endpoint:    opts.Endpoint,
+			Key:         opts.Key,
 		})
 
 	case opts.Query && opts.Help:
@@ -137,20 +210,17 @@ func run() error {
 		return nil
 
 	case opts.Query:
-		if opts.Outdated {
-			return pacman.Query(nil, pacman.QueryParameters{
-				Stdout:  os.Stdout,
-				Stderr:  os.Stderr,
-				Stdin:   os.Stdin,
-				Upgrade: true,
-			})
-		}
-		return pacman.Query(args(), pacman.QueryParameters{
-			Info:   opts.Info,
-			List:   opts.List,
-			Stdout: os.Stdout,
-			Stderr: os.Stderr,
-			Stdin:  os.Stdin,
+		return pack.QueryContext(ctx, targets, pack.QueryParameters{
+			Info:     opts.Info,
+			List:     opts.List,
+			Outdated: opts.Outdated,
+			Stdout:   os.Stdout,
+			Stderr:   os.Stderr,
+			Stdin:    os.Stdin,
+			Distro:   opts.Distro,
+			Arch:     opts.Arch,
+			Insecure: opts.Insecure,
+			Endpoint: opts.Endpoint,
 		})
 
 	case opts.Build && opts.Help:
@@ -158,12 +228,20 @@ func run() error {
 		return nil
 
 	case opts.Build:
-		return pack.Build(args(), pack.BuildParameters{
+		return pack.BuildContext(ctx, targets, pack.BuildParameters{
 			Dir:       opts.Dir,
 			Quick:     opts.Quick,
 			Syncbuild: opts.Syncbuild,
 			Rmdeps:    opts.Rmdeps,
 			Garbage:   opts.Garbage,
+			Push:      opts.Registry,
+			Insecure:  opts.Insecure,
+			Distro:    opts.Distro,
+			Endpoint:  opts.Endpoint,
+			Key:       opts.Key,
+			Timeout:   opts.Timeout,
+			Retries:   opts.Retries,
+			Jobs:      opts.Jobs,
 			Stdout:    os.Stdout,
 			Stderr:    os.Stderr,
 			Stdin:     os.Stdin,
@@ -174,16 +252,55 @@ func run() error {
 		return nil
 
 	case opts.Util:
-		return pack.Util(args(), pack.UtilParameters{
-			Stdout:  os.Stdout,
-			Stderr:  os.Stderr,
-			Stdin:   os.Stdin,
-			Gen:     opts.Gen,
-			Armor:   opts.Armor,
-			Recv:    opts.Recv,
-			Setpkgr: opts.Setpkgr,
-			Flutter: opts.Flutter,
-			Gocli:   opts.Gocli,
+		return pack.Util(targets, pack.UtilParameters{
+			Stdout:      os.Stdout,
+			Stderr:      os.Stderr,
+			Stdin:       os.Stdin,
+			Gen:         opts.Gen,
+			Armor:       opts.Armor,
+			Recv:        opts.Recv,
+			Setpkgr:     opts.Setpkgr,
+			Flutter:     opts.Flutter,
+			Gocli:       opts.Gocli,
+			RestoreConf: opts.RestoreConf,
+		})
+
+	case opts.Open && opts.Help:
+		fmt.Println(msgs.OpenHelp)
+		return nil
+
+	case opts.Open:
+		return pack.OpenContext(ctx, targets, pack.OpenParameters{
+			Stdout:   os.Stdout,
+			Stderr:   os.Stderr,
+			Stdin:    os.Stdin,
+			Endpoint: opts.Endpoint,
+			Dir:      opts.Storage,
+			Name:     opts.Name,
+			Port:     opts.Port,
+			Cert:     opts.Cert,
+			Key:      opts.Certkey,
+			GpgDir:   opts.Gpgdir,
+		})
+
+	case opts.Database && opts.Help:
+		fmt.Println(msgs.DatabaseHelp)
+		return nil
+
+	case opts.Database:
+		return pack.DatabaseContext(ctx, targets, pack.DatabaseParameters{
+			Stdout:   os.Stdout,
+			Stderr:   os.Stderr,
+			Stdin:    os.Stdin,
+			Add:      opts.Add,
+			Delete:   opts.Delete,
+			Prune:    opts.Prune,
+			Insecure: opts.Insecure,
+			Endpoint: opts.Endpoint,
+			Distro:   opts.Distro,
+			Arch:     opts.Arch,
+			KeyURL:   opts.KeyURL,
+			NoKey:    opts.NoKey,
 		})
 
 	case opts.Version:
@@ -199,6 +316,100 @@ func run() error {
 	}
 }
 
+// Apply configuration files and registry profiles to options. Explicitly
+// provided flags take precedence over values from registry profile, profile
+// values over config defaults and config defaults over builtin ones. Returns
+// targets with profile names expanded to registry/owner.
+func configure(parser *flags.Parser) ([]string, error) {
+	c, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	var profile *config.Registry
+	use := func(r *config.Registry) error {
+		if profile != nil && *profile != *r {
+			return fmt.Errorf("targets should not reference different registry profiles")
+		}
+		profile = r
+		return nil
+	}
+
+	targets := args()
+	for i, target := range targets {
+		expanded, r := c.Expand(target)
+		if r == nil {
+			continue
+		}
+		err = use(r)
+		if err != nil {
+			return nil, err
+		}
+		targets[i] = expanded
+	}
+	if opts.Registry != `` {
+		expanded, r := c.Expand(opts.Registry + "/")
+		if r != nil {
+			err = use(r)
+			if err != nil {
+				return nil, err
+			}
+			opts.Registry = expanded
+		}
+	}
+
+	var r config.Registry
+	if profile != nil {
+		r = *profile
+	}
+	fill(&opts.Dir, c.Defaults.Dir, "/var/cache/pacman/pkg")
+	fill(&opts.Distro, r.Distro, c.Defaults.Distro)
+	fill(&opts.Arch, c.Defaults.Arch)
+	if !opts.Sync && !opts.Database {
+		// Sync detects distribution and architecture of host.
+		fill(&opts.Distro, "archlinux")
+		fill(&opts.Arch, "x86_64")
+	}
+	fill(&opts.Endpoint, r.Endpoint, c.Defaults.Endpoint, "/api/packages/arch")
+	fill(&opts.Key, r.Key, c.Defaults.Key)
+	fill(&opts.KeyURL, r.KeyURL)
+	opts.NoKey = opts.NoKey || r.NoKey
+	if opts.Timeout == 0 {
+		opts.Timeout = c.Defaults.Timeout
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = pack.DefaultTimeout
+	}
+	if opts.Jobs == 0 {
+		opts.Jobs = c.Defaults.Jobs
+	}
+	if opts.Jobs == 0 {
+		opts.Jobs = pack.DefaultJobs
+	}
+	if !parser.FindOptionByLongName("retries").IsSet() {
+		opts.Retries = pack.DefaultRetries
+		if c.Defaults.Retries != nil {
+			opts.Retries = *c.Defaults.Retries
+		}
+	}
+	if r.Protocol == `` {
+		opts.Insecure = opts.Insecure || c.Defaults.Insecure
+	} else {
+		opts.Insecure = opts.Insecure || r.Insecure()
+	}
+	return targets, nil
+}
+
+// Set option to first non empty value if it was not provided with flag.
+func fill(opt *string, values ...string) {
+	for _, v := range values {
+		if *opt != `` {
+			return
+		}
+		*opt = v
+	}
+}
+
 // This gets list of all arguements and removes command, string args and bool
 // args from list. New string arguements should be added to stringargs variable
 // for command to work properly.
@@ -206,6 +417,9 @@ func run() error {
 func args() []string {
 	var stringargs = []string{
 		"-d", "--dir", "--endpoint", "--distro", "--architecture",
+		"--name", "-p", "--port", "--storage", "--cert", "--certkey",
+		"--gpgdir", "--registry", "--key", "--timeout", "--retries",
+		"--jobs", "--keyurl",
 	}
 	var filtered []string
 	for i, v := range os.Args {
@@ -6,7 +6,6 @@
 package msgs
 
 import (
-	"fmt"
 	"os"
 	"strings"
 
@@ -16,14 +15,19 @@ import (
 var Help = `Simplified version of pacman
 
 operations:
-	pack {-S --sync}   [options] [(registry)/(owner)/package(s)]
-	pack {-P --push}   [options] [(registry)/(owner)/package(s)]
-	pack {-R --remove} [options] [(registry)/(owner)/package(s)]
-	pack {-Q --query}  [options] [(registry)/(owner)/package(s)]
-	pack {-B --build}  [options] [(registry)/(owner)/package(s)]
-	pack {-U --util}   [options] [args]
-
-use 'pack {-h --help}' with an operation for available options`
+	pack {-S --sync}     [options] [(registry)/(owner)/package(s)]
+	pack {-P --push}     [options] [(registry)/(owner)/package(s)]
+	pack {-R --remove}   [options] [(registry)/(owner)/package(s)]
+	pack {-Q --query}    [options] [(registry)/(owner)/package(s)]
+	pack {-B --build}    [options] [(registry)/(owner)/package(s)]
+	pack {-U --util}     [options] [args]
+	pack {-O --open}     [options]
+	pack {-D --database} [options] [registry/(owner)]
+
+use 'pack {-h --help}' with an operation for available options
+use 'pack --json' with an operation to get machine-readable output
+use 'pack --dry-run' with an operation to print commands and requests instead of executing them
+defaults and registry profiles are read from /etc/pack.conf and ~/.config/pack/config.toml`
 
 var SyncHelp = `Syncronize packages
 
@@ -32,8 +36,15 @@ options:
 	-y, --refresh     Download fresh package databases from the server (-yy force)
 	-u, --upgrade     Upgrade installed packages (-uu enables downgrade)
 	-f, --force       Reinstall up to date targets
+	-w, --insecure    Use HTTP instead of HTTPS for added registries
+	    --endpoint    Use custom API endpoints rootpath
+	    --distro      Distribution in server URLs (default from os-release)
+	    --architecture Architecture in server URLs (default host)
+	    --keyurl      HTTPS URL of owner key, trusted without confirmation
+	    --nokey       Do not import owner key, it should be already trusted
+	    --pin         Add packages with exact version to IgnorePkg
 
-usage:  pack {-S --sync} [options] <(registry)/(owner)/package(s)>`
+usage:  pack {-S --sync} [options] <(registry)/(owner)/package(s)(@version)>`
 
 var PushHelp = `Push packages
 
@@ -42,8 +53,12 @@ options:
 	-w, --insecure  Push package over HTTP instead of HTTPS
 	    --distro    Assign custom distribution in registry (default archlinux)
 	    --endpoint  Use custom API endpoints rootpath
+	    --key <fpr> Use key with provided fingerprint instead of default
+	    --timeout   Timeout for connection and registry response (default 30s)
+	    --retries   Retries for failed uploads (default 3)
+	    --jobs      Amount of simultaneous uploads (default 4)
 
-usage:  pack {-P --push} [options] <registry/(owner)/package(s)>`
+usage:  pack {-P --push} [options] <registry/(owner)/package(s)(.pkg.tar.zst)>`
 
 var RemoveHelp = `Remove packages
 
@@ -52,6 +67,9 @@ options:
 	-a, --norecurs Leave package dependencies in the system (removed by default)
 	-j, --nocfgs   Leave package configs in the system (removed by default)
 	    --cascade  Remove packages and all packages that depend on them
+	-w, --insecure Remove remote packages over HTTP instead of HTTPS
+	    --endpoint Use custom API endpoints rootpath
+	    --key <fpr> Sign remote deletions with provided key
 
 usage:  pack {-R --remove} [options] <(registry)/(owner)/package(s)>`
 
@@ -61,6 +79,10 @@ options:
 	-i, --info     View package information (-ii for backup files)
 	-l, --list     List the files owned by the queried package
 	-o, --outdated List outdated packages
+	-w, --insecure Query remote registries over HTTP instead of HTTPS
+	    --distro   Distribution of remote database (default archlinux)
+	    --architecture Architecture of remote database (default x86_64)
+	    --endpoint Use custom API endpoints rootpath
 
 usage:  pack {-Q --query} [options] <(registry)/(owner)/package(s)>`
 
@@ -72,6 +94,11 @@ options:
 	-s, --syncbuild Syncronize dependencies and build target
 	-r, --rmdeps    Remove installed dependencies after a successful build
 	-g, --garbage   Do not clean workspace before and after build
+	    --registry <registry/(owner)> Push built packages to registry
+	    --timeout   Timeout for connection and registry response (default 30s)
+	    --retries   Retries for failed uploads (default 3)
+	    --jobs      Amount of simultaneous uploads (default 4)
+	    --key <fpr> Sign packages with provided key instead of default
 
 usage:  pack {-B --build} [options] <(registry)/(owner)/package(s)>`
 
@@ -85,9 +112,38 @@ options:
         --setpkgr Automatically set packager in makepkg.conf
         --flutter Generate PKGBUILD, app.sh and app.desktop for flutter application
         --gocli   Generate PKGBUILD for CLI utility in go
+        --restore-conf Restore pacman.conf from backup left by interrupted sync
 
 usage:  pack {-U --util} [options] <(args)>`
 
+var OpenHelp = `Open registry
+
+options:
+	    --name <name>    Registry host used for databases (default localhost:<port>)
+	-p, --port <port>    Port to listen on (default 8080)
+	    --storage <dir>  Directory to store packages (default /var/lib/pack)
+	    --endpoint       Use custom API endpoints rootpath
+	    --cert <file>    TLS certificate, run on HTTPS when provided
+	    --certkey <file> TLS certificate key
+	    --gpgdir <dir>   Custom GnuPG home to verify signatures
+
+usage:  pack {-O --open} [options]`
+
+var DatabaseHelp = `Manage databases added by pack
+
+options:
+	-w, --insecure    Use HTTP instead of HTTPS for added registries
+	    --endpoint    Use custom API endpoints rootpath
+	    --distro      Distribution in server URLs (default from os-release)
+	    --architecture Architecture in server URLs (default host)
+	    --keyurl      HTTPS URL of owner key, trusted without confirmation
+	    --nokey       Do not import owner key, it should be already trusted
+	    --add         Add databases for provided registries and owners
+	    --delete      Remove provided databases from pacman.conf
+	    --prune       Remove databases without installed packages
+
+usage:  pack {-D --database} [options] <registry/(owner)>`
+
 var Version = `             Pack - package manager.
           Copyright (C) 2023 FMNX team
      
@@ -99,12 +155,10 @@ var Version = `             Pack - package manager.
 
 var Color bool
 
+// Colors are enabled with Color option in pacman.conf, and disabled if it
+// can't be read, so that package can be used on systems without pacman.
 func init() {
-	b, err := os.ReadFile("/etc/pacman.conf")
-	if err != nil {
-		fmt.Println("unable to read pacman configuration")
-		os.Exit(1)
-	}
+	b, _ := os.ReadFile("/etc/pacman.conf")
 	Color = strings.Contains(string(b), "\nColor\n")
 	if !Color {
 		color.NoColor = true
@@ -117,5 +171,7 @@ func init() {
 		PushHelp = strings.Join([]string{"🚀", PushHelp}, " ")
 		BuildHelp = strings.Join([]string{"🔐", BuildHelp}, " ")
 		UtilHelp = strings.Join([]string{"📄", UtilHelp}, " ")
+		OpenHelp = strings.Join([]string{"🌐", OpenHelp}, " ")
+		DatabaseHelp = strings.Join([]string{"🗄", DatabaseHelp}, " ")
 	}
 }
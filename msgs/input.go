@@ -6,13 +6,13 @@
 package msgs
 
 import (
-	"bufio"
 	"io"
 	"log"
 	"os"
 	"strings"
 
 	"github.com/fatih/color"
+	"golang.org/x/term"
 )
 
 // Asks the user for confirmation. A user must type in "yes" or "no" and then
@@ -20,8 +20,6 @@ import (
 // count as confirmations. If the input is not recognized, it will ask again.
 // The function does not return until it gets a valid response from the user.
 func AskForConfirmation(in io.Reader, out io.Writer, msg string) bool {
-	reader := bufio.NewReader(os.Stdin)
-
 	dots := color.New(color.FgWhite, color.Bold, color.FgHiBlue).Sprintf(":: ")
 	msg = color.New(color.Bold).Sprintf(msg + "? [Y/n] ")
 	msg = dots + msg
@@ -29,7 +27,7 @@ func AskForConfirmation(in io.Reader, out io.Writer, msg string) bool {
 	for {
 		out.Write([]byte(msg))
 
-		response, err := reader.ReadString('\n')
+		response, err := ReadLine(in)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -43,3 +41,58 @@ func AskForConfirmation(in io.Reader, out io.Writer, msg string) bool {
 		}
 	}
 }
+
+// Asks the user yes/no question, empty answer is treated as yes. Unlike
+// AskForConfirmation, unrecognized answer is treated as no and error is
+// returned if input is closed before answer.
+func Confirm(in io.Reader, out io.Writer, msg string) (bool, error) {
+	dots := color.New(color.FgWhite, color.Bold, color.FgHiBlue).Sprintf(":: ")
+	out.Write([]byte(dots + color.New(color.Bold).Sprintf(msg+"? [Y/n] ")))
+
+	response, err := ReadLine(in)
+	if err != nil && response == `` {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(response)) {
+	case ``, "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// Reads single line from input without trailing newline. Input is read byte
+// by byte, so that nothing after the line is consumed and reader can be
+// passed to other prompts or commands. Line is returned with io.EOF if input
+// ends without newline.
+func ReadLine(in io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := in.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				return string(line), nil
+			}
+			line = append(line, b[0])
+		}
+		if err != nil {
+			return string(line), err
+		}
+	}
+}
+
+// Asks the user for password without echoing it to terminal. If input is
+// not a terminal, password is read as single line.
+func AskPassword(in io.Reader, out io.Writer, msg string) ([]byte, error) {
+	dots := color.New(color.FgWhite, color.Bold, color.FgHiBlue).Sprintf(":: ")
+	out.Write([]byte(dots + color.New(color.Bold).Sprintf(msg+": ")))
+	defer out.Write([]byte("\n"))
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		return term.ReadPassword(int(f.Fd()))
+	}
+	line, err := ReadLine(in)
+	if err != nil && line == `` {
+		return nil, err
+	}
+	return []byte(line), nil
+}
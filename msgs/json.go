@@ -0,0 +1,50 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package msgs
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// Write structured JSON events instead of colored text messages.
+var JSON bool
+
+// Event statuses.
+const (
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusError   = "error"
+	StatusWarning = "warning"
+)
+
+// Structured event, that is written as single JSON line in JSON mode.
+type Event struct {
+	Step     string `json:"step,omitempty"`
+	Package  string `json:"package,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Registry string `json:"registry,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Result   any    `json:"result,omitempty"`
+}
+
+var emitmu sync.Mutex
+
+// Write event to provided io.Writer if JSON mode is enabled.
+func Emit(w io.Writer, e Event) {
+	if !JSON {
+		return
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		b, _ = json.Marshal(Event{Status: StatusError, Error: err.Error()})
+	}
+	emitmu.Lock()
+	defer emitmu.Unlock()
+	w.Write(append(b, '\n'))
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 
 	"github.com/fatih/color"
 	"github.com/mitchellh/ioprogress"
@@ -18,6 +19,10 @@ import (
 // Write an announcement message with dots prefix and bold text to provided
 // io.Writer.
 func Amsg(w io.Writer, msg string) {
+	if JSON {
+		Emit(w, Event{Step: msg, Status: StatusRunning})
+		return
+	}
 	dots := color.New(color.FgWhite, color.Bold, color.FgHiBlue).Sprintf(":: ")
 	msg = color.New(color.Bold).Sprintf(msg)
 	w.Write([]byte(dots + msg + "...\n"))
@@ -26,9 +31,23 @@ func Amsg(w io.Writer, msg string) {
 // Write step message, with enumeration which should represent state of program
 // execution.
 func Smsg(w io.Writer, msg string, i, t int) {
+	if JSON {
+		Emit(w, Event{Step: msg, Status: StatusRunning})
+		return
+	}
 	w.Write([]byte(fmt.Sprintf("(%d/%d) %s...\n", i, t, msg)))
 }
 
+// Write warning message with yellow prefix to provided io.Writer.
+func Wmsg(w io.Writer, msg string) {
+	if JSON {
+		Emit(w, Event{Step: msg, Status: StatusWarning})
+		return
+	}
+	prefix := color.New(color.Bold, color.FgYellow).Sprintf("warning: ")
+	w.Write([]byte(prefix + msg + "\n"))
+}
+
 type LoaderParameters struct {
 	Current int
 	Total   int
@@ -39,29 +58,38 @@ type LoaderParameters struct {
 // Function that will give terminal drawer for provided message, that can be
 // further used in different IO operations.
 func Loader(p *LoaderParameters) func(int64, int64) error {
+	if JSON {
+		return func(int64, int64) error { return nil }
+	}
 	width, _, err := term.GetSize(0)
 	if err != nil {
 		return nil
 	}
+	return ioprogress.DrawTerminalf(p.Output, loaderFormat(p, width))
+}
+
+// Get function formatting loader line with message, bar and percentage, that
+// fits into terminal width.
+func loaderFormat(p *LoaderParameters, width int) func(int64, int64) string {
 	prefix := fmt.Sprintf("(%d/%d) %s", p.Current, p.Total, p.Msg)
 
 	if len(prefix) > width {
-		return ioprogress.DrawTerminalf(p.Output, func(i1, i2 int64) string {
+		return func(i1, i2 int64) string {
 			return prefix[:width-3] + "..."
-		})
+		}
 	}
 
 	loaderwidth := int(float64(width) * 0.35)
 	paddingWidth := width - len(prefix) - loaderwidth - 7
 
 	if paddingWidth < 0 {
-		return ioprogress.DrawTerminalf(p.Output, func(i1, i2 int64) string {
+		return func(i1, i2 int64) string {
 			return prefix
-		})
+		}
 	}
 
 	padding := strings.Repeat(" ", paddingWidth)
-	return ioprogress.DrawTerminalf(p.Output, func(progress, total int64) string {
+	return func(progress, total int64) string {
 		prcntg := float32(progress) / float32(total) * 100
 
 		current := int((float64(progress) / float64(total)) * float64(loaderwidth))
@@ -71,5 +99,78 @@ func Loader(p *LoaderParameters) func(int64, int64) error {
 		)
 
 		return fmt.Sprintf("%s%s%s %.0f", prefix, padding, bar, prcntg) + "%"
-	})
+	}
+}
+
+// Group of loaders, that can be drawn simultaneously from different
+// goroutines. Each loader occupies separate terminal line, all unfinished
+// lines are redrawn on every update.
+type Loaders struct {
+	mu     sync.Mutex
+	out    io.Writer
+	lines  []*loaderLine
+	active int
+	drawn  int
+}
+
+type loaderLine struct {
+	text     string
+	finished bool
+}
+
+// Create group of loaders writing to provided io.Writer.
+func NewLoaders(out io.Writer) *Loaders {
+	return &Loaders{out: out}
+}
+
+// Get terminal drawer for provided message, line for loader is added to
+// group on first draw.
+func (l *Loaders) Loader(p *LoaderParameters) func(int64, int64) error {
+	width, _, err := term.GetSize(0)
+	if JSON || err != nil {
+		return func(int64, int64) error { return nil }
+	}
+	format := loaderFormat(p, width)
+	var line *loaderLine
+	return func(progress, total int64) error {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		// Repeated upload after finished one is drawn on new line.
+		if line == nil || line.finished && progress != -1 {
+			line = &loaderLine{}
+			l.lines = append(l.lines, line)
+		}
+		if progress == -1 && total == -1 {
+			line.finished = true
+		} else {
+			line.text = format(progress, total)
+		}
+		return l.draw()
+	}
+}
+
+// Redraw unfinished lines. Finished lines at the top are left as is and
+// are not redrawn anymore, so group does not outgrow terminal height.
+func (l *Loaders) draw() error {
+	var b strings.Builder
+	if l.drawn > 1 {
+		fmt.Fprintf(&b, "\033[%dA", l.drawn-1)
+	}
+	for i, line := range l.lines[l.active:] {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("\r\033[2K" + line.text)
+	}
+	for l.active < len(l.lines) && l.lines[l.active].finished {
+		l.active++
+	}
+	l.drawn = len(l.lines) - l.active
+	// Cursor is moved to the next line when all lines are finished, so
+	// that following output does not overwrite them.
+	if l.drawn == 0 {
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(l.out, b.String())
+	return err
 }
@@ -7,15 +7,20 @@ package pack
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path"
 	"strings"
+	"time"
 
 	"fmnx.su/core/pack/msgs"
 	"fmnx.su/core/pack/pacman"
+	"fmnx.su/core/pack/pacman/pkgfile"
+	"fmnx.su/core/pack/pgp"
 )
 
 // Parameters that can be used to build packages.
@@ -34,6 +39,22 @@ type BuildParameters struct {
 	Rmdeps bool
 	// Do not clean workspace before and after build.
 	Garbage bool
+	// Fingerprint of key used to sign packages, default key is used if empty.
+	Key string
+	// Push built packages to provided registry/(owner) after build.
+	Push string
+	// Use HTTP instead of https when pushing packages.
+	Insecure bool
+	// Custom distribution for which packages are pushed.
+	Distro string
+	// API rootpath of registry, owner is inserted before last element.
+	Endpoint string
+	// Timeout for connecting to registry and waiting for it's response.
+	Timeout time.Duration
+	// Amount of retries for failed uploads.
+	Retries int
+	// Amount of packages uploaded simultaneously.
+	Jobs int
 }
 
 func builddefault() *BuildParameters {
@@ -44,26 +65,38 @@ func builddefault() *BuildParameters {
 		Dir:       "/var/cache/pacman/pkg",
 		Syncbuild: true,
 		Rmdeps:    true,
+		Distro:    "archlinux",
+		Endpoint:  defaultEndpoint,
+		Timeout:   DefaultTimeout,
+		Retries:   DefaultRetries,
+		Jobs:      DefaultJobs,
 	}
 }
 
 // Build package in current directory with provided arguements
 func Build(args []string, prms ...BuildParameters) error {
+	return BuildContext(context.Background(), args, prms...)
+}
+
+// BuildContext is like Build, but git, makepkg and uploads are interrupted
+// when context is canceled.
+func BuildContext(ctx context.Context, args []string, prms ...BuildParameters) error {
 	p := formOptions(prms, builddefault)
 
 	msgs.Amsg(p.Stdout, "Building packages")
 
 	msgs.Smsg(p.Stdout, "Running GnuPG check", 1, 2)
-	err := checkGnupg()
+	key, err := checkGnupg(p.Key)
 	if err != nil {
 		return err
 	}
 
 	msgs.Smsg(p.Stdout, "Validating packager identity", 2, 2)
-	err = validatePackager()
+	err = validatePackager(key)
 	if err != nil {
 		return err
 	}
+	email := key.Email()
 
 	var builddirs []string
 
@@ -76,25 +109,35 @@ func Build(args []string, prms ...BuildParameters) error {
 	}
 
 	for _, arg := range args {
-		dir, err := cloneOrPullDir(p.Stdout, p.Stderr, arg)
+		dir, err := cloneOrPullDir(ctx, p.Stdout, p.Stderr, arg)
 		if err != nil {
 			return err
 		}
 		builddirs = append(builddirs, dir)
 	}
 
-	for _, dir := range builddirs {
+	// Sources are not cloned in dry run mode, so build order is unknown.
+	install := make([]bool, len(builddirs))
+	if dryrun == nil {
+		builddirs, install, err = buildOrder(ctx, builddirs)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, dir := range builddirs {
 		msgs.Amsg(p.Stdout, "Building package with makepkg")
-		err = pacman.Makepkg(pacman.MakepkgParameters{
+		err = pacman.MakepkgContext(ctx, pacman.MakepkgParameters{
 			Sign:       true,
+			GpgKey:     p.Key,
 			Dir:        dir,
-			Stdout:     p.Stdout,
+			Stdout:     cmdOutput(p.Stdout, p.Stderr),
 			Stderr:     p.Stderr,
 			Stdin:      p.Stdin,
 			Clean:      !p.Garbage,
 			CleanBuild: !p.Garbage,
 			Force:      !p.Garbage,
-			Install:    p.Syncbuild,
+			Install:    p.Syncbuild || install[i],
 			RmDeps:     p.Rmdeps,
 			SyncDeps:   p.Syncbuild,
 			Needed:     !p.Syncbuild,
@@ -104,6 +147,14 @@ func Build(args []string, prms ...BuildParameters) error {
 			return errors.Join(err)
 		}
 
+		var built []string
+		if p.Push != `` && dryrun == nil {
+			built, err = pacman.PackageListContext(ctx, dir)
+			if err != nil {
+				return err
+			}
+		}
+
 		msgs.Amsg(p.Stdout, "Moving package to cache")
 		movecommand := "sudo mv " + dir + "/*.pkg.tar.zst* " + p.Dir
 		cmd := exec.Command("bash", "-c", movecommand)
@@ -111,36 +162,195 @@ func Build(args []string, prms ...BuildParameters) error {
 		if err != nil {
 			return err
 		}
+
+		msgs.Emit(p.Stdout, msgs.Event{
+			Step:    "build",
+			Package: ejectLastPathArg(dir),
+			Status:  msgs.StatusDone,
+		})
+
+		if p.Push != `` && dryrun == nil {
+			err = pushBuilt(ctx, p, built, email)
+			if err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
 
-// Ensure, that user have created gnupg keys for package signing before package
-// is built and cached.
-func checkGnupg() error {
-	hd, err := os.UserHomeDir()
-	if err != nil {
-		return err
+// Push packages that were just built and moved to cache directory. Files
+// listed by makepkg, that were not created (like debug packages for any
+// architecture), are skipped.
+func pushBuilt(ctx context.Context, p *BuildParameters, files []string, email string) error {
+	registry, owner := splitRegistry(p.Push)
+	pp := PushParameters{
+		Stdout:    p.Stdout,
+		Stderr:    p.Stderr,
+		Stdin:     p.Stdin,
+		Directory: p.Dir,
+		Insecure:  p.Insecure,
+		Distro:    p.Distro,
+		Endpoint:  p.Endpoint,
+		Key:       p.Key,
+		Timeout:   p.Timeout,
+		Retries:   p.Retries,
+		Jobs:      p.Jobs,
 	}
-	gpgdir, err := os.ReadDir(path.Join(hd, ".gnupg"))
-	if err != nil {
-		return errors.New(msgs.ErrGnuPGprivkeyNotFound)
+
+	msgs.Amsg(p.Stdout, "Pushing packages to "+p.Push)
+	var mds []PackageMetadata
+	for _, file := range files {
+		md := PackageMetadata{
+			FileName: path.Base(file),
+			Registry: registry,
+			Owner:    owner,
+		}
+		_, err := os.Stat(path.Join(p.Dir, md.FileName))
+		if errors.Is(err, os.ErrNotExist) {
+			msgs.Wmsg(p.Stdout, "skipping "+md.FileName+", package was not created by makepkg")
+			continue
+		}
+		info, err := pkgfile.OpenInfo(path.Join(p.Dir, md.FileName))
+		if err != nil {
+			return fmt.Errorf("unable to read package %s: %w", md.FileName, err)
+		}
+		md.Name = info.Name
+		md.Version = info.Version
+		md.Arch = info.Arch
+		mds = append(mds, md)
 	}
-	for _, de := range gpgdir {
-		if strings.Contains(de.Name(), "private-keys") {
-			return nil
+	if len(mds) == 0 {
+		return errors.New("no built packages found in " + p.Dir)
+	}
+	return pushAll(ctx, &pp, mds, email)
+}
+
+// Sort build directories, so that packages are built after packages they
+// depend on. Also returns which packages should be installed after build,
+// because other targets depend on them.
+func buildOrder(ctx context.Context, dirs []string) ([]string, []bool, error) {
+	if len(dirs) < 2 {
+		return dirs, make([]bool, len(dirs)), nil
+	}
+
+	infos := make([]*pacman.Srcinfo, len(dirs))
+	provided := map[string]int{}
+	for i, dir := range dirs {
+		info, err := pacman.PrintSrcinfoContext(ctx, dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		infos[i] = info
+		for _, name := range info.Names {
+			provided[name] = i
+		}
+		for _, name := range info.Provides {
+			provided[depName(name)] = i
 		}
 	}
-	return errors.New(msgs.ErrGnuPGprivkeyNotFound)
+
+	// Dependencies of each target among other targets.
+	deps := make([][]int, len(dirs))
+	needed := make([]bool, len(dirs))
+	for i, info := range infos {
+		var all []string
+		all = append(all, info.Depends...)
+		all = append(all, info.MakeDepends...)
+		all = append(all, info.CheckDepends...)
+		for _, dep := range all {
+			j, ok := provided[depName(dep)]
+			if !ok || j == i {
+				continue
+			}
+			deps[i] = append(deps[i], j)
+			needed[j] = true
+		}
+	}
+
+	var order []int
+	done := make([]bool, len(dirs))
+	for len(order) < len(dirs) {
+		var progress bool
+		for i := range dirs {
+			if done[i] || !builtAll(deps[i], done) {
+				continue
+			}
+			order = append(order, i)
+			done[i] = true
+			progress = true
+			break
+		}
+		if !progress {
+			return nil, nil, fmt.Errorf(
+				"unable to order builds, dependency cycle: %s",
+				dependencyCycle(infos, deps, done),
+			)
+		}
+	}
+
+	sorted := make([]string, len(dirs))
+	install := make([]bool, len(dirs))
+	for i, j := range order {
+		sorted[i] = dirs[j]
+		install[i] = needed[j]
+	}
+	return sorted, install, nil
 }
 
-// Validate, that packager defined in /etc/makepkg.conf matches signer
-// authority in GnuPG.
-func validatePackager() error {
-	keySigner, err := gnuPGIdentity()
-	if err != nil {
-		return err
+// Get dependency name without version constraint.
+func depName(dep string) string {
+	i := strings.IndexAny(dep, "<>=")
+	if i < 0 {
+		return dep
+	}
+	return dep[:i]
+}
+
+func builtAll(deps []int, done []bool) bool {
+	for _, dep := range deps {
+		if !done[dep] {
+			return false
+		}
 	}
+	return true
+}
+
+// Find dependency cycle among packages that are not built yet, and format it
+// as sequence of package bases.
+func dependencyCycle(infos []*pacman.Srcinfo, deps [][]int, done []bool) string {
+	start := 0
+	for done[start] {
+		start++
+	}
+	// Walk over unbuilt dependencies until some package is visited twice,
+	// each unbuilt package has at least one unbuilt dependency.
+	visited := map[int]int{}
+	var path []int
+	for i := start; ; {
+		if pos, ok := visited[i]; ok {
+			path = append(path[pos:], i)
+			break
+		}
+		visited[i] = len(path)
+		path = append(path, i)
+		for _, dep := range deps[i] {
+			if !done[dep] {
+				i = dep
+				break
+			}
+		}
+	}
+	var names []string
+	for _, i := range path {
+		names = append(names, infos[i].Base)
+	}
+	return strings.Join(names, " -> ")
+}
+
+// Validate, that packager defined in /etc/makepkg.conf matches one of
+// identities of signing key.
+func validatePackager(key *pgp.Key) error {
 	f, err := os.ReadFile("/etc/makepkg.conf")
 	if err != nil {
 		return err
@@ -150,25 +360,12 @@ func validatePackager() error {
 		return errors.New(msgs.ErrNoPackager)
 	}
 	confPackager := strings.Split(splt[1], "\"\n")[0]
-	if confPackager != keySigner {
-		return errors.New(msgs.ErrSignerMissmatch)
-	}
-	return nil
-}
-
-// Returns name and email from GnuPG. Error, if did not succeed.
-func gnuPGIdentity() (string, error) {
-	gnukey := `gpg --with-colons -k | awk -F: '$1=="uid" {print $10; exit}'`
-	cmd := exec.Command("bash", "-c", gnukey)
-	var b bytes.Buffer
-	cmd.Stdout = &b
-	cmd.Stderr = &b
-	err := cmd.Run()
-	if err != nil {
-		o := b.String()
-		return ``, errors.New("unable to get gnupg identity: " + o)
+	for _, ident := range key.Identities() {
+		if confPackager == ident {
+			return nil
+		}
 	}
-	return strings.ReplaceAll(b.String(), "\n", ""), nil
+	return errors.New(msgs.ErrSignerMissmatch)
 }
 
 // Eject last name from directory or link.
@@ -179,7 +376,7 @@ func ejectLastPathArg(s string) string {
 
 // This function will clone provided repository to cache directory and return
 // name of that directory.
-func cloneOrPullDir(outw, errw io.Writer, repo string) (string, error) {
+func cloneOrPullDir(ctx context.Context, outw, errw io.Writer, repo string) (string, error) {
 	uhd, err := os.UserHomeDir()
 	if err != nil {
 		return ``, err
@@ -193,10 +390,10 @@ func cloneOrPullDir(outw, errw io.Writer, repo string) (string, error) {
 	gitdir := path.Join(uhd, ".packcache", project)
 
 	var errbuf bytes.Buffer
-	cmd := exec.Command("git", "clone", "https://"+repo, gitdir)
+	cmd := exec.CommandContext(ctx, "git", "clone", "https://"+repo, gitdir)
 	cmd.Stderr = io.MultiWriter(errw, &errbuf)
-	cmd.Stdout = outw
-	err = cmd.Run()
+	cmd.Stdout = cmdOutput(outw, errw)
+	err = execute(cmd)
 	if err != nil {
 		if strings.Contains(errbuf.String(), "and is not an empty directory") {
 			msgs.Amsg(outw, "Pulling changes")
@@ -204,9 +401,9 @@ func cloneOrPullDir(outw, errw io.Writer, repo string) (string, error) {
 			if err != nil {
 				return ``, err
 			}
-			cmd := exec.Command("git", "pull")
+			cmd := exec.CommandContext(ctx, "git", "pull")
 			cmd.Stderr = errw
-			cmd.Stdout = outw
+			cmd.Stdout = cmdOutput(outw, errw)
 			return gitdir, cmd.Run()
 		}
 		return ``, err
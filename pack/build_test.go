@@ -0,0 +1,136 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pack
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"fmnx.su/core/pack/pacman"
+)
+
+// Output of makepkg --printsrcinfo for package base with provided names and
+// dependency lines (like "depends = a>=1").
+func srcinfo(base string, names []string, lines ...string) string {
+	s := "pkgbase = " + base + "\n\tpkgver = 1.0\n\tpkgrel = 1\n"
+	for _, line := range lines {
+		s += "\t" + line + "\n"
+	}
+	for _, name := range names {
+		s += "\npkgname = " + name + "\n"
+	}
+	return s
+}
+
+func TestBuildOrder(t *testing.T) {
+	cases := []struct {
+		name    string
+		pkgs    map[string]string
+		dirs    []string
+		order   []string
+		install []bool
+		err     string
+	}{
+		{
+			name:    "single",
+			dirs:    []string{"a"},
+			order:   []string{"a"},
+			install: []bool{false},
+		},
+		{
+			name: "independent",
+			pkgs: map[string]string{
+				"a": srcinfo("a", []string{"a"}, "depends = glibc"),
+				"b": srcinfo("b", []string{"b"}, "makedepends = go"),
+			},
+			dirs:    []string{"a", "b"},
+			order:   []string{"a", "b"},
+			install: []bool{false, false},
+		},
+		{
+			name: "chain",
+			pkgs: map[string]string{
+				"app":  srcinfo("app", []string{"app"}, "depends = lib>=1.0"),
+				"lib":  srcinfo("lib", []string{"lib"}, "makedepends = tool"),
+				"tool": srcinfo("tool", []string{"tool"}),
+			},
+			dirs:    []string{"app", "lib", "tool"},
+			order:   []string{"tool", "lib", "app"},
+			install: []bool{true, true, false},
+		},
+		{
+			name: "split package and provides",
+			pkgs: map[string]string{
+				"app":  srcinfo("app", []string{"app"}, "checkdepends = libfoo-docs", "depends = foo.so=1-64"),
+				"base": srcinfo("base", []string{"libfoo", "libfoo-docs"}, "provides = foo.so=1-64"),
+			},
+			dirs:    []string{"app", "base"},
+			order:   []string{"base", "app"},
+			install: []bool{true, false},
+		},
+		{
+			name: "self dependency",
+			pkgs: map[string]string{
+				"a": srcinfo("a", []string{"a", "a-libs"}, "depends_x86_64 = a-libs"),
+				"b": srcinfo("b", []string{"b"}),
+			},
+			dirs:    []string{"a", "b"},
+			order:   []string{"a", "b"},
+			install: []bool{false, false},
+		},
+		{
+			name: "cycle",
+			pkgs: map[string]string{
+				"a": srcinfo("a", []string{"a"}),
+				"b": srcinfo("b", []string{"b"}, "depends = c"),
+				"c": srcinfo("c", []string{"c"}, "makedepends = d"),
+				"d": srcinfo("d", []string{"d"}, "depends = b", "depends = a"),
+			},
+			dirs: []string{"a", "b", "c", "d"},
+			err:  "dependency cycle: b -> c -> d -> b",
+		},
+	}
+	for _, c := range cases {
+		var responses []pacman.FakeResponse
+		for dir, info := range c.pkgs {
+			responses = append(responses, pacman.FakeResponse{
+				Prefix: "makepkg --printsrcinfo",
+				Dir:    dir,
+				Stdout: info,
+			})
+		}
+		fakePacman(t, responses...)
+
+		order, install, err := buildOrder(context.Background(), c.dirs)
+		switch {
+		case c.err != ``:
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s: expected error %q, got %v", c.name, c.err, err)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", c.name, err)
+		case !reflect.DeepEqual(order, c.order) || !reflect.DeepEqual(install, c.install):
+			t.Errorf("%s: got %q %v, want %q %v", c.name, order, install, c.order, c.install)
+		}
+	}
+}
+
+func TestDepName(t *testing.T) {
+	cases := map[string]string{
+		"glibc":       "glibc",
+		"lib>=1.0":    "lib",
+		"lib<2":       "lib",
+		"foo.so=1-64": "foo.so",
+		"python>3.11": "python",
+	}
+	for dep, want := range cases {
+		if got := depName(dep); got != want {
+			t.Errorf("depName(%q) = %q, want %q", dep, got, want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pack
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"fmnx.su/core/pack/config"
+)
+
+// Default timeout for connecting to registry and waiting for it's response.
+const DefaultTimeout = 30 * time.Second
+
+// Default amount of retries for requests failed with server errors or
+// dropped connections.
+const DefaultRetries = 3
+
+// Default amount of simultaneous uploads.
+const DefaultJobs = 4
+
+// Send request to registry with credentials found for registry host, see
+// sendPublic. Should be used only for registry URLs, so that credentials are
+// not leaked to other hosts.
+func send(req *http.Request, timeout time.Duration, retries int) (*http.Response, error) {
+	creds, err := config.LookupCredentials(req.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+	creds.Apply(req)
+	return sendPublic(req, timeout, retries)
+}
+
+// Send request without credentials. Responses with status other than 200 are
+// returned as errors, in that case body is already closed. Requests failed
+// with server errors or dropped connections are repeated up to provided
+// amount of retries with exponential backoff, request body is recreated with
+// GetBody for each attempt. In dry run mode requests other than GET are only
+// recorded.
+func sendPublic(req *http.Request, timeout time.Duration, retries int) (*http.Response, error) {
+	if dryrun != nil && req.Method != http.MethodGet {
+		return dryrun.request(req)
+	}
+
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	client := http.Client{
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           (&net.Dialer{Timeout: timeout}).DialContext,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+		},
+	}
+	rewindable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		resp, err := client.Do(req)
+		retry := rewindable && attempt <= retries && req.Context().Err() == nil
+		switch {
+		case err != nil && (!retry || !retryable(err)):
+			return nil, attemptsError(err, attempt)
+		case err == nil && resp.StatusCode == http.StatusOK:
+			return resp, nil
+		case err == nil && (!retry || resp.StatusCode < 500):
+			return nil, attemptsError(statusError(resp), attempt)
+		case err == nil:
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, attemptsError(req.Context().Err(), attempt)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if req.GetBody != nil {
+			req.Body, err = req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// Check wether request failed with error, after which it can be repeated.
+func retryable(err error) bool {
+	var neterr net.Error
+	if errors.As(err, &neterr) && neterr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// Error response of registry.
+type responseError struct {
+	code int
+	msg  string
+}
+
+func (e responseError) Error() string {
+	return e.msg
+}
+
+// Form error from response status and body, body is closed.
+func statusError(resp *http.Response) error {
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Join(err, responseError{resp.StatusCode, resp.Status})
+	}
+	return responseError{resp.StatusCode, resp.Status + " " + strings.TrimSpace(string(b))}
+}
+
+// Check wether registry responded that requested file does not exist.
+func notFound(err error) bool {
+	var rerr responseError
+	return errors.As(err, &rerr) && rerr.code == http.StatusNotFound
+}
+
+func attemptsError(err error, attempts int) error {
+	if attempts == 1 {
+		return err
+	}
+	return fmt.Errorf("failed after %d attempts: %w", attempts, err)
+}
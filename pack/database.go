@@ -0,0 +1,282 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"fmnx.su/core/pack/msgs"
+	"fmnx.su/core/pack/pacman"
+	"fmnx.su/core/pack/pacman/conf"
+)
+
+// Comment marking sections of pacman.conf added by pack.
+const packComment = "added by pack"
+
+// Parameters for managing databases, that pack added to pacman.conf.
+type DatabaseParameters struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	Stdin  io.Reader
+
+	// Add databases for provided registries and owners.
+	Add bool
+	// Remove provided databases.
+	Delete bool
+	// Remove databases, that have no installed packages.
+	Prune bool
+	// Use HTTP instead of https.
+	Insecure bool
+	// API rootpath of registry, owner is inserted before last element.
+	Endpoint string
+	// Distribution used in server URLs, detected from /etc/os-release if
+	// empty.
+	Distro string
+	// Architecture used in server URLs, detected from pacman.conf or uname if
+	// empty.
+	Arch string
+	// URL of public key of registry owner, key endpoint of registry is used
+	// if empty.
+	KeyURL string
+	// Do not import key of registry owner, it should be already trusted in
+	// pacman keyring.
+	NoKey bool
+}
+
+func databasedefault() *DatabaseParameters {
+	return &DatabaseParameters{
+		Endpoint: defaultEndpoint,
+		Stdout:   os.Stdout,
+		Stderr:   os.Stderr,
+		Stdin:    os.Stdin,
+	}
+}
+
+// Database in pacman.conf added by pack.
+type DatabaseInfo struct {
+	Name    string   `json:"name"`
+	Servers []string `json:"servers"`
+}
+
+// Manage databases, that pack added to pacman.conf. Databases are listed if
+// no action is provided. Targets are registry/owner pairs or database names.
+func Database(args []string, prms ...DatabaseParameters) error {
+	return DatabaseContext(context.Background(), args, prms...)
+}
+
+// DatabaseContext is like Database, but commands are interrupted when
+// context is canceled. Changes are rolled back on failure or interruption.
+func DatabaseContext(ctx context.Context, args []string, prms ...DatabaseParameters) error {
+	p := formOptions(prms, databasedefault)
+
+	if !p.Add && !p.Delete && !p.Prune {
+		return listDatabases(p)
+	}
+	if (p.Add || p.Delete) && len(args) == 0 {
+		return errors.New("no databases provided, run 'pack -Dh'")
+	}
+
+	tx, err := begin()
+	if err != nil {
+		return err
+	}
+	c, err := conf.Open(conf.Path)
+	if err != nil {
+		return errors.Join(err, tx.commit())
+	}
+	var removed []string
+	var perr error
+	switch {
+	case p.Add:
+		err = addDatabases(ctx, c, p, args)
+	case p.Delete:
+		removed, err = deleteDatabases(c, args)
+	case p.Prune:
+		removed, perr = pruneDatabases(ctx, c)
+		if ctx.Err() != nil {
+			err, perr = perr, nil
+		}
+	}
+	if err == nil {
+		err = c.Save(conf.Path, true)
+	}
+	if err == nil && len(removed) > 0 {
+		err = tx.track(removed...)
+	}
+	if err == nil && len(removed) > 0 {
+		err = removeDatabaseFiles(removed)
+	}
+	if err != nil {
+		return errors.Join(err, ctx.Err(), tx.rollback())
+	}
+	for _, name := range removed {
+		msgs.Emit(p.Stdout, msgs.Event{
+			Step:     "remove database",
+			Registry: name,
+			Status:   msgs.StatusDone,
+		})
+	}
+	return errors.Join(perr, tx.commit())
+}
+
+// Get databases in pacman.conf added by pack.
+func packDatabases(c *conf.Config) []*conf.Section {
+	var rez []*conf.Section
+	for _, s := range c.Repositories() {
+		if s.Comment == packComment {
+			rez = append(rez, s)
+		}
+	}
+	return rez
+}
+
+// Print databases added by pack with their servers, credentials are hidden.
+func listDatabases(p *DatabaseParameters) error {
+	c, err := conf.Open(conf.Path)
+	if err != nil {
+		return err
+	}
+	var infos []DatabaseInfo
+	for _, s := range packDatabases(c) {
+		info := DatabaseInfo{Name: s.Name}
+		for _, srv := range s.Values("Server") {
+			info.Servers = append(info.Servers, mask(srv))
+		}
+		infos = append(infos, info)
+	}
+	if msgs.JSON {
+		msgs.Emit(p.Stdout, msgs.Event{
+			Step:   "database",
+			Status: msgs.StatusDone,
+			Result: infos,
+		})
+		return nil
+	}
+	for _, info := range infos {
+		fmt.Fprintf(p.Stdout, "%s %s\n", info.Name, strings.Join(info.Servers, " "))
+	}
+	return nil
+}
+
+// Add databases for provided registry/owner targets.
+func addDatabases(ctx context.Context, c *conf.Config, p *DatabaseParameters, args []string) error {
+	err := detectHost(ctx, &p.Distro, &p.Arch)
+	if err != nil {
+		return err
+	}
+	sp := &SyncParameters{
+		Stdout:   p.Stdout,
+		Stderr:   p.Stderr,
+		Stdin:    p.Stdin,
+		Insecure: p.Insecure,
+		Endpoint: p.Endpoint,
+		Distro:   p.Distro,
+		Arch:     p.Arch,
+		KeyURL:   p.KeyURL,
+		NoKey:    p.NoKey,
+	}
+	for _, arg := range args {
+		registry, owner, _ := strings.Cut(strings.Trim(arg, "/"), "/")
+		if registry == `` || strings.Contains(owner, "/") {
+			return fmt.Errorf("database should be provided as registry/owner: %s", arg)
+		}
+		name := databaseName(registry, owner)
+		if c.Section(name) != nil {
+			return fmt.Errorf("database %s already exists in pacman.conf", name)
+		}
+		msgs.Amsg(p.Stdout, "Adding database "+name)
+		err = addDatabase(ctx, c, sp, registry, owner)
+		if err != nil {
+			return err
+		}
+		msgs.Emit(p.Stdout, msgs.Event{
+			Step:     "add database",
+			Registry: name,
+			Status:   msgs.StatusDone,
+		})
+	}
+	return nil
+}
+
+// Remove provided databases, only databases added by pack can be removed.
+func deleteDatabases(c *conf.Config, args []string) ([]string, error) {
+	var removed []string
+	for _, arg := range args {
+		name := arg
+		if registry, owner, ok := strings.Cut(strings.Trim(arg, "/"), "/"); ok {
+			name = databaseName(registry, owner)
+		}
+		s := c.Section(name)
+		if s == nil {
+			return nil, fmt.Errorf("database %s is not found in pacman.conf", name)
+		}
+		if s.Comment != packComment {
+			return nil, fmt.Errorf("database %s was not added by pack", name)
+		}
+		c.RemoveRepository(name)
+		removed = append(removed, name)
+	}
+	return removed, nil
+}
+
+// Remove databases added by pack, that have no installed packages. Databases,
+// which packages could not be listed, are kept and errors are returned after
+// other databases are checked.
+func pruneDatabases(ctx context.Context, c *conf.Config) ([]string, error) {
+	var removed []string
+	var errs []error
+	for _, s := range packDatabases(c) {
+		installed, err := hasInstalled(ctx, s.Name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to check packages of %s: %w", s.Name, err))
+			continue
+		}
+		if !installed {
+			removed = append(removed, s.Name)
+		}
+	}
+	for _, name := range removed {
+		c.RemoveRepository(name)
+	}
+	return removed, errors.Join(errs...)
+}
+
+// Check wether any package from sync database is installed. Database, that
+// was never downloaded, has no installed packages.
+func hasInstalled(ctx context.Context, name string) (bool, error) {
+	pkgs, err := pacman.ListRepoContext(ctx, []string{name})
+	if errors.Is(err, pacman.ErrTargetNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		_, serr := os.Stat(databaseFiles(name)[0])
+		if errors.Is(serr, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, pkg := range pkgs {
+		if pkg.Installed {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Remove sync database files of removed databases.
+func removeDatabaseFiles(names []string) error {
+	args := []string{"rm", "-f"}
+	for _, name := range names {
+		args = append(args, databaseFiles(name)...)
+	}
+	return call(exec.Command("sudo", args...))
+}
@@ -0,0 +1,68 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pack
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"fmnx.su/core/pack/pacman"
+	"fmnx.su/core/pack/pacman/conf"
+)
+
+func TestPruneDatabases(t *testing.T) {
+	c, err := conf.Parse(strings.NewReader("[options]\n\n[core]\nInclude = /etc/pacman.d/mirrorlist\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"used.fmnx.su", "unused.fmnx.su", "missing.fmnx.su", "unknown.fmnx.su"} {
+		owner, _, _ := strings.Cut(name, ".")
+		addConfDatabase(c, "https://fmnx.su/api/packages/"+owner+"/arch", name, "archlinux", "x86_64")
+	}
+	runner := fakePacman(t,
+		pacman.FakeResponse{
+			Prefix: "pacman -Sl used.fmnx.su",
+			Stdout: "used.fmnx.su pack 0.6.2-1 [installed]\nused.fmnx.su ainst 0.1-1\n",
+		},
+		pacman.FakeResponse{
+			Prefix: "pacman -Sl unused.fmnx.su",
+			Stdout: "unused.fmnx.su ainst 0.1-1\n",
+		},
+		pacman.FakeResponse{
+			// Database was added, but never downloaded.
+			Prefix: "pacman -Sl missing.fmnx.su",
+			Stderr: "error: failed to prepare transaction\n",
+			Err:    pacman.FakeExitError(1),
+		},
+		pacman.FakeResponse{
+			Prefix: "pacman -Sl unknown.fmnx.su",
+			Stderr: "error: repository \"unknown.fmnx.su\" was not found\n",
+			Err:    pacman.FakeExitError(1),
+		},
+	)
+
+	removed, err := pruneDatabases(context.Background(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"unused.fmnx.su", "missing.fmnx.su", "unknown.fmnx.su"}
+	if !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed %q, want %q", removed, want)
+	}
+	if len(runner.Commands()) != 4 {
+		t.Errorf("expected each database to be listed, got %q", runner.Commands())
+	}
+	for _, name := range want {
+		if c.Section(name) != nil {
+			t.Errorf("database %s should be removed from pacman.conf", name)
+		}
+	}
+	if c.Section("used.fmnx.su") == nil || c.Section("core") == nil {
+		t.Error("databases with installed packages should be kept")
+	}
+}
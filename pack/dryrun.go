@@ -0,0 +1,165 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pack
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"fmnx.su/core/pack/msgs"
+	"fmnx.su/core/pack/pacman"
+	"fmnx.su/core/pack/pacman/conf"
+)
+
+// Recorder used in dry run mode, nil if commands should be executed.
+var dryrun *recorder
+
+// Enable dry run mode. Commands modifying system, file changes and requests
+// to registries (except read only GET requests) are printed to provided
+// writer instead of being executed. Secrets, like credentials in headers
+// and URLs, are masked.
+func DryRun(w io.Writer) {
+	dryrun = &recorder{w: w}
+	pacman.DryRun = dryrun
+	conf.DryRun = dryrun.file
+}
+
+// Recorder printing commands, requests and file changes instead of
+// executing them.
+type recorder struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Headers, which values are not printed.
+var secretHeaders = []string{"Authorization", "Cookie"}
+
+// Credentials embedded into URLs.
+var urlUserinfo = regexp.MustCompile(`://[^/@\s]+@`)
+
+// Run command modifying system, or record it in dry run mode.
+func execute(cmd *exec.Cmd) error {
+	if dryrun != nil {
+		return dryrun.Run(cmd)
+	}
+	return cmd.Run()
+}
+
+// Record command instead of running it, implements pacman.Runner.
+func (r *recorder) Run(cmd *exec.Cmd) error {
+	var args []string
+	for _, arg := range cmd.Args {
+		args = append(args, quote(mask(arg)))
+	}
+	line := strings.Join(args, " ")
+	if cmd.Dir != `` {
+		line = "(cd " + quote(cmd.Dir) + " && " + line + ")"
+	}
+	r.write("exec", line, nil)
+	return nil
+}
+
+// Record request and return empty response with status 200.
+func (r *recorder) request(req *http.Request) (*http.Response, error) {
+	var lines []string
+	for name, values := range req.Header {
+		value := strings.Join(values, ", ")
+		if contains(secretHeaders, name) {
+			value = "***"
+		}
+		lines = append(lines, name+": "+value)
+	}
+	sort.Strings(lines)
+	if req.ContentLength > 0 {
+		lines = append(lines, fmt.Sprintf("Content-Length: %d", req.ContentLength))
+	}
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	r.write("http", req.Method+" "+mask(req.URL.String()), lines)
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Body:       http.NoBody,
+		Request:    req,
+	}, nil
+}
+
+// Record file change as a list of added and removed lines.
+func (r *recorder) file(file string, data []byte) error {
+	prev, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	before := strings.Split(string(prev), "\n")
+	after := strings.Split(string(data), "\n")
+	var lines []string
+	for _, line := range before {
+		if !contains(after, line) {
+			lines = append(lines, "- "+mask(line))
+		}
+	}
+	for _, line := range after {
+		if !contains(before, line) {
+			lines = append(lines, "+ "+mask(line))
+		}
+	}
+	r.write("write", file, lines)
+	return nil
+}
+
+func (r *recorder) write(kind, action string, details []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if msgs.JSON {
+		msgs.Emit(r.w, msgs.Event{
+			Step:   "dry-run",
+			Status: msgs.StatusDone,
+			Result: map[string]any{
+				"kind":    kind,
+				"action":  action,
+				"details": details,
+			},
+		})
+		return
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "[dry-run] %s: %s\n", kind, action)
+	for _, line := range details {
+		fmt.Fprintf(&b, "    %s\n", line)
+	}
+	r.w.Write(b.Bytes())
+}
+
+// Hide credentials embedded into URLs.
+func mask(s string) string {
+	return urlUserinfo.ReplaceAllString(s, "://***@")
+}
+
+// Quote shell argument if it contains special characters.
+func quote(arg string) string {
+	if arg != `` && !strings.ContainsAny(arg, " \t\n'\"\\$`*?&|;<>()[]{}#~!") {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+func contains(lines []string, line string) bool {
+	for _, l := range lines {
+		if l == line {
+			return true
+		}
+	}
+	return false
+}
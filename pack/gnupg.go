@@ -0,0 +1,92 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pack
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"fmnx.su/core/pack/msgs"
+	"fmnx.su/core/pack/pgp"
+)
+
+// Environment variables, that can be used to provide exported key file
+// instead of GnuPG keyring, and passphrase for private key.
+const (
+	keyFileEnv    = "PACK_KEYFILE"
+	passphraseEnv = "PACK_PASSPHRASE"
+)
+
+// Load packager key from key file or GnuPG keyring. Key is selected by
+// fingerprint, first valid key of user is used if fingerprint is empty. When
+// secret is true, private key is loaded, see unlockKey.
+func loadKey(secret bool, fingerprint string) (*pgp.Key, error) {
+	var keys []*pgp.Key
+	var err error
+	if file := os.Getenv(keyFileEnv); file != `` {
+		keys, err = pgp.ReadFile(file)
+	} else {
+		keys, err = pgp.GnuPG(secret, fingerprint)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return pgp.Select(keys, fingerprint)
+}
+
+// Load private key and unlock it, so that it can be used for signing.
+// Passphrase is taken from environment, or asked with provided input and
+// output.
+func unlockKey(fingerprint string, stdin io.Reader, stderr io.Writer) (*pgp.Key, error) {
+	key, err := loadKey(true, fingerprint)
+	if err != nil || !key.Encrypted() {
+		return key, err
+	}
+
+	passphrase := []byte(os.Getenv(passphraseEnv))
+	if len(passphrase) == 0 {
+		passphrase, err = msgs.AskPassword(
+			stdin, stderr, "Enter passphrase for key "+key.Fingerprint(),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return key, key.Unlock(passphrase)
+}
+
+// Ensure, that user have created gnupg keys for package signing before package
+// is built and cached. Returns key, that will be used for signing.
+func checkGnupg(fingerprint string) (*pgp.Key, error) {
+	key, err := loadKey(false, fingerprint)
+	if err != nil {
+		return nil, errors.Join(errors.New(msgs.ErrGnuPGprivkeyNotFound), err)
+	}
+	if os.Getenv(keyFileEnv) != `` {
+		return key, nil
+	}
+	fprs, err := pgp.GnuPGSecretFingerprints()
+	if err != nil {
+		return nil, err
+	}
+	for _, fpr := range fprs {
+		if fpr == key.Fingerprint() {
+			return key, nil
+		}
+	}
+	return nil, errors.New(msgs.ErrGnuPGprivkeyNotFound)
+}
+
+// Returns name and email from GnuPG. Error, if did not succeed.
+func gnuPGIdentity() (string, error) {
+	key, err := loadKey(false, ``)
+	if err != nil {
+		return ``, errors.Join(errors.New("unable to get gnupg identity"), err)
+	}
+	return key.Identity(), nil
+}
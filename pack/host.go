@@ -0,0 +1,87 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pack
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"fmnx.su/core/pack/pacman/conf"
+)
+
+// File describing distribution of host.
+const osRelease = "/etc/os-release"
+
+// Distribution IDs from os-release, that are named differently in registry.
+var distroNames = map[string]string{
+	"arch": "archlinux",
+}
+
+// Fill empty distribution and architecture with values detected on host.
+func detectHost(ctx context.Context, distro, arch *string) error {
+	var err error
+	if *distro == `` {
+		*distro, err = detectDistro()
+		if err != nil {
+			return err
+		}
+	}
+	if *arch == `` {
+		*arch, err = detectArch(ctx)
+	}
+	return err
+}
+
+// Detect distribution of host from ID field in /etc/os-release.
+func detectDistro() (string, error) {
+	f, err := os.Open(osRelease)
+	if err != nil {
+		return ``, fmt.Errorf("unable to detect distribution, use --distro: %w", err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(sc.Text()), "=")
+		if !ok || key != "ID" {
+			continue
+		}
+		value = strings.Trim(value, `"'`)
+		if name, ok := distroNames[value]; ok {
+			return name, nil
+		}
+		return value, nil
+	}
+	if sc.Err() != nil {
+		return ``, sc.Err()
+	}
+	return ``, errors.New("unable to detect distribution, use --distro: no ID in " + osRelease)
+}
+
+// Detect architecture of host from Architecture option in pacman.conf, uname
+// is used if option is missing or set to auto.
+func detectArch(ctx context.Context) (string, error) {
+	c, err := conf.Open(conf.Path)
+	if err != nil {
+		return ``, err
+	}
+	if s := c.Section("options"); s != nil {
+		fields := strings.Fields(s.Get("Architecture"))
+		if len(fields) > 0 && fields[0] != "auto" {
+			return fields[0], nil
+		}
+	}
+	out, err := exec.CommandContext(ctx, "uname", "-m").Output()
+	if err != nil {
+		return ``, fmt.Errorf("unable to detect architecture, use --architecture: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
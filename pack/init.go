@@ -7,11 +7,17 @@ package pack
 
 import (
 	"bytes"
-	"errors"
+	"io"
 	"os/exec"
-	"strings"
+	"path"
+
+	"fmnx.su/core/pack/msgs"
+	"fmnx.su/core/pack/pacman"
 )
 
+// Default API rootpath of arch package registry.
+const defaultEndpoint = "/api/packages/arch"
+
 func formOptions[Opts any](arr []Opts, getdefault func() *Opts) *Opts {
 	if len(arr) != 1 {
 		return getdefault()
@@ -19,13 +25,48 @@ func formOptions[Opts any](arr []Opts, getdefault func() *Opts) *Opts {
 	return &arr[0]
 }
 
+// Run command capturing it's error output, failure is returned as
+// *pacman.Error.
 func call(cmd *exec.Cmd) error {
 	var buf bytes.Buffer
 	cmd.Stderr = &buf
-	err := cmd.Run()
+	err := execute(cmd)
 	if err != nil {
-		out := strings.ReplaceAll(buf.String(), "error: ", "")
-		return errors.New(strings.TrimSuffix(out, "\n"))
+		return pacman.NewError(cmd.Args, buf.String(), err)
 	}
 	return nil
 }
+
+// Get writer for raw output of external commands. In JSON mode output is
+// redirected to stderr, so that stdout contains only events.
+func cmdOutput(stdout, stderr io.Writer) io.Writer {
+	if msgs.JSON {
+		return stderr
+	}
+	return stdout
+}
+
+// Form registry URL for provided owner and API endpoint. Owner is placed before
+// last element of endpoint, so that with owner "john" default endpoint turns
+// into "/api/packages/john/arch". Additional elements are appended to the end.
+func registryURL(insecure bool, registry, endpoint, owner string, elems ...string) string {
+	return protocol(insecure) + "://" + registryPath(registry, endpoint, owner, elems...)
+}
+
+// Form registry path without protocol prefix, see registryURL.
+func registryPath(registry, endpoint, owner string, elems ...string) string {
+	if endpoint == `` {
+		endpoint = defaultEndpoint
+	}
+	elems = append([]string{
+		registry, path.Dir(endpoint), owner, path.Base(endpoint),
+	}, elems...)
+	return path.Join(elems...)
+}
+
+func protocol(insecure bool) string {
+	if insecure {
+		return "http"
+	}
+	return "https"
+}
@@ -0,0 +1,114 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pack
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"fmnx.su/core/pack/msgs"
+	"fmnx.su/core/pack/pacman"
+	"fmnx.su/core/pack/pgp"
+)
+
+// Signature level written for databases added by sync. Packages should be
+// signed by trusted key, databases are not signed by registries.
+const defaultSigLevel = "Required DatabaseOptional"
+
+// Download public key of database owner, import it to pacman keyring and
+// locally sign it, so that pacman trusts packages from database. Fingerprints
+// of keys served by registry are shown to user, who should confirm them even
+// in quick mode, keys from URL provided explicitly are trusted. Keys are never
+// downloaded over HTTP. Credentials are sent only when key is served by
+// registry itself.
+func importKey(ctx context.Context, p *SyncParameters, database, keyurl string, registry bool) error {
+	if strings.HasPrefix(keyurl, "http://") {
+		return fmt.Errorf(
+			"key of %s can not be trusted over HTTP, provide HTTPS --keyurl or use --nokey",
+			database,
+		)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, keyurl, nil)
+	if err != nil {
+		return err
+	}
+	do := sendPublic
+	if registry {
+		do = send
+	}
+	resp, err := do(req, DefaultTimeout, DefaultRetries)
+	if registry && notFound(err) {
+		msgs.Wmsg(p.Stdout, "registry does not provide key for "+database+
+			", packages should be signed by key trusted in pacman keyring")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to get key for %s: %w", database, err)
+	}
+	defer resp.Body.Close()
+	armored, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	keys, err := pgp.Read(bytes.NewReader(armored))
+	if err != nil {
+		return fmt.Errorf("unable to read key for %s: %w", database, err)
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no keys provided for %s", database)
+	}
+
+	out := cmdOutput(p.Stdout, p.Stderr)
+	var fprs []string
+	for _, key := range keys {
+		fprs = append(fprs, key.Fingerprint())
+		fmt.Fprintf(out, "    %s %s\n", key.Fingerprint(), key.Identity())
+	}
+	msgs.Emit(p.Stdout, msgs.Event{
+		Step:     "key",
+		Registry: database,
+		Status:   msgs.StatusRunning,
+		Result:   fprs,
+	})
+	if registry {
+		ok, err := msgs.Confirm(p.Stdin, out, "Trust keys of "+database)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("keys of %s are not trusted", database)
+		}
+	}
+
+	f, err := os.CreateTemp(``, "pack-key")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(armored)
+	err = errors.Join(err, f.Close())
+	if err != nil {
+		return err
+	}
+
+	kp := pacman.KeyParameters{
+		Sudo:   true,
+		Stdout: out,
+		Stderr: p.Stderr,
+		Stdin:  p.Stdin,
+	}
+	err = pacman.KeyAddContext(ctx, []string{f.Name()}, kp)
+	if err != nil {
+		return err
+	}
+	return pacman.KeyLocalSignContext(ctx, fprs, kp)
+}
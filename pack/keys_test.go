@@ -0,0 +1,41 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pack
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+// Keys downloaded over HTTP can be replaced on the way, so they should not
+// be imported and signed, even if user does not confirm fingerprints.
+func TestImportKeyInsecure(t *testing.T) {
+	cases := []struct {
+		p        SyncParameters
+		keyurl   string
+		registry bool
+	}{
+		{SyncParameters{Insecure: true}, "http://localhost:8080/api/packages/john/arch/key", true},
+		{SyncParameters{Quick: true, Insecure: true}, "http://localhost:8080/api/packages/john/arch/key", true},
+		{SyncParameters{KeyURL: "http://keys.org/john"}, "http://keys.org/john", false},
+	}
+	for _, c := range cases {
+		runner := fakePacman(t)
+		var out bytes.Buffer
+		c.p.Stdout = &out
+		c.p.Stderr = &out
+		c.p.Stdin = strings.NewReader("y\n")
+		err := importKey(context.Background(), &c.p, "john.localhost:8080", c.keyurl, c.registry)
+		if err == nil || !strings.Contains(err.Error(), "can not be trusted over HTTP") {
+			t.Errorf("%s: expected error, got %v", c.keyurl, err)
+		}
+		if cmds := runner.Commands(); len(cmds) != 0 {
+			t.Errorf("%s: unexpected commands %q", c.keyurl, cmds)
+		}
+	}
+}
@@ -0,0 +1,558 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pack
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"fmnx.su/core/pack/msgs"
+	"fmnx.su/core/pack/pacman"
+)
+
+// Parameters that will be used to run pack registry.
+type OpenParameters struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	Stdin  io.Reader
+
+	// API rootpath of registry, owner is inserted before last element.
+	Endpoint string
+	// Directory where package files and databases will be stored.
+	Dir string
+	// Domain name of registry, used to name pacman databases. Should match
+	// host (with port) used by clients, localhost with port if empty.
+	Name string
+	// Port to listen on.
+	Port string
+	// Path to TLS certificate, registry runs on HTTP if not provided.
+	Cert string
+	// Path to TLS key.
+	Key string
+	// Custom GnuPG home directory to verify signatures.
+	GpgDir string
+	// Maximum size of pushed package file in bytes.
+	MaxSize int64
+}
+
+func opendefault() *OpenParameters {
+	return &OpenParameters{
+		Stdout:   os.Stdout,
+		Stderr:   os.Stderr,
+		Stdin:    os.Stdin,
+		Endpoint: defaultEndpoint,
+		Dir:      "/var/lib/pack",
+		Port:     "8080",
+		MaxSize:  maxPackageSize,
+	}
+}
+
+// Maximum time difference between signed delete request and server time.
+const removeTimeout = time.Minute * 5
+
+// Default limit for size of pushed package file.
+const maxPackageSize = 1 << 30
+
+// Limit for size of signature in delete request.
+const maxSignatureSize = 64 * 1024
+
+// Time given to pending requests to complete when registry is closed.
+const shutdownTimeout = time.Second * 30
+
+// Open registry, that will accept pushed packages and serve pacman databases
+// for each owner, distribution and architecture.
+func Open(args []string, prms ...OpenParameters) error {
+	return OpenContext(context.Background(), args, prms...)
+}
+
+// OpenContext is like Open, but registry is gracefully shut down when
+// context is canceled.
+func OpenContext(ctx context.Context, args []string, prms ...OpenParameters) error {
+	p := formOptions(prms, opendefault)
+
+	if p.Endpoint == `` {
+		p.Endpoint = defaultEndpoint
+	}
+	if p.MaxSize == 0 {
+		p.MaxSize = maxPackageSize
+	}
+	if p.Name == `` {
+		p.Name = "localhost:" + p.Port
+	}
+	err := os.MkdirAll(p.Dir, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	r := &registry{OpenParameters: *p}
+	srv := http.Server{
+		Addr:    ":" + p.Port,
+		Handler: r,
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	shutdown := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		shutdown <- srv.Shutdown(sctx)
+	}()
+
+	msgs.Amsg(p.Stdout, "Opening registry "+p.Name+" on port "+p.Port)
+	if p.Cert != `` || p.Key != `` {
+		err = srv.ListenAndServeTLS(p.Cert, p.Key)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return <-shutdown
+	}
+	return err
+}
+
+type registry struct {
+	OpenParameters
+	mu sync.Mutex
+}
+
+func (r *registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	owner, route, ok := r.route(req.URL.Path)
+	if !ok || (owner != `` && !validName(owner)) {
+		http.NotFound(w, req)
+		return
+	}
+
+	var err error
+	switch {
+	case route == "push" && req.Method == http.MethodPut:
+		err = r.push(owner, req)
+	case route == "remove" && req.Method == http.MethodDelete:
+		err = r.remove(owner, req)
+	case route == "key" && req.Method == http.MethodGet:
+		r.serveKey(w, req, owner)
+		return
+	case req.Method == http.MethodGet:
+		r.serveFile(w, req, owner, route)
+		return
+	default:
+		http.NotFound(w, req)
+		return
+	}
+
+	if err != nil {
+		fmt.Fprintf(r.Stderr, "%s %s: %v\n", req.Method, req.URL.Path, err)
+		var rerr registryError
+		if errors.As(err, &rerr) {
+			http.Error(w, rerr.msg, rerr.status)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(r.Stdout, "%s %s: ok\n", req.Method, req.URL.Path)
+}
+
+// Error that will be returned to client with provided status code.
+type registryError struct {
+	status int
+	msg    string
+}
+
+func (e registryError) Error() string {
+	return e.msg
+}
+
+func badRequest(msg string) error {
+	return registryError{status: http.StatusBadRequest, msg: msg}
+}
+
+// Eject owner and remaining route from request path. Owner is located before
+// last element of endpoint, and might be empty.
+func (r *registry) route(urlpath string) (string, string, bool) {
+	prefix := path.Dir(r.Endpoint) + "/"
+	base := path.Base(r.Endpoint) + "/"
+	rest, ok := strings.CutPrefix(path.Clean(urlpath), prefix)
+	if !ok {
+		return ``, ``, false
+	}
+	if route, ok := strings.CutPrefix(rest, base); ok {
+		return ``, route, true
+	}
+	owner, rest, ok := strings.Cut(rest, "/")
+	if !ok {
+		return ``, ``, false
+	}
+	route, ok := strings.CutPrefix(rest, base)
+	return owner, route, ok
+}
+
+// Name of pacman database, matching section name pack sync adds to
+// pacman.conf for this registry and owner.
+func (r *registry) dbname(owner string) string {
+	return databaseName(r.Name, owner)
+}
+
+// Serve package files and databases for provided owner.
+func (r *registry) serveFile(w http.ResponseWriter, req *http.Request, owner, route string) {
+	splt := strings.Split(route, "/")
+	if len(splt) != 3 || strings.HasPrefix(splt[2], ".") {
+		http.NotFound(w, req)
+		return
+	}
+	http.ServeFile(w, req, path.Join(r.Dir, owner, route))
+}
+
+// Accept pushed package, verify its signature and add it to databases.
+func (r *registry) push(owner string, req *http.Request) error {
+	filename := req.Header.Get("filename")
+	email := req.Header.Get("email")
+	distro := req.Header.Get("distro")
+	if distro == `` {
+		distro = "archlinux"
+	}
+	if !validName(filename) || !strings.HasSuffix(filename, ".pkg.tar.zst") {
+		return badRequest("not valid package file name: " + filename)
+	}
+	if !validName(distro) || email == `` {
+		return badRequest("email and distro should be provided")
+	}
+	sign, err := hex.DecodeString(req.Header.Get("sign"))
+	if err != nil {
+		return badRequest("unable to decode signature: " + err.Error())
+	}
+
+	tmp, err := os.MkdirTemp(r.Dir, ".push")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	pkgpath := path.Join(tmp, filename)
+	f, err := os.Create(pkgpath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, http.MaxBytesReader(nil, req.Body, r.MaxSize))
+	err = errors.Join(err, f.Close())
+	var maxerr *http.MaxBytesError
+	if errors.As(err, &maxerr) {
+		return registryError{
+			status: http.StatusRequestEntityTooLarge,
+			msg:    fmt.Sprintf("package file exceeds %d bytes", maxerr.Limit),
+		}
+	}
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(pkgpath+".sig", sign, 0644)
+	if err != nil {
+		return err
+	}
+
+	fpr, err := r.verify(pkgpath, pkgpath+".sig", email)
+	if err != nil {
+		return err
+	}
+	info, err := checkPkgInfo(pkgpath)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err = r.authorize(owner, fpr, true)
+	if err != nil {
+		return err
+	}
+
+	archs, err := r.archs(owner, distro, info.Arch)
+	if err != nil {
+		return err
+	}
+	for _, arch := range archs {
+		dir := path.Join(r.Dir, owner, distro, arch)
+		err = os.MkdirAll(dir, os.ModePerm)
+		if err != nil {
+			return err
+		}
+		for _, fn := range []string{filename, filename + ".sig"} {
+			err = copyFile(path.Join(tmp, fn), path.Join(dir, fn))
+			if err != nil {
+				return err
+			}
+		}
+		err = pacman.RepoAdd(r.dbname(owner)+".db.tar.gz", filename, pacman.RepoAddParameters{
+			Dir:    dir,
+			Stdout: io.Discard,
+			Stderr: r.Stderr,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List architectures package should be added to. Packages built for any
+// architecture are added to every existing architecture for distribution.
+func (r *registry) archs(owner, distro, arch string) ([]string, error) {
+	if arch != "any" {
+		return []string{arch}, nil
+	}
+	des, err := os.ReadDir(path.Join(r.Dir, owner, distro))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	var archs []string
+	for _, de := range des {
+		if de.IsDir() && de.Name() != "any" {
+			archs = append(archs, de.Name())
+		}
+	}
+	if len(archs) == 0 {
+		archs = append(archs, "x86_64")
+	}
+	return archs, nil
+}
+
+// Remove package after validating signed delete request.
+func (r *registry) remove(owner string, req *http.Request) error {
+	email := req.Header.Get("email")
+	distro := req.Header.Get("distro")
+	target := req.Header.Get("target")
+	version := req.Header.Get("version")
+	arch := req.Header.Get("arch")
+	t := req.Header.Get("time")
+
+	for _, v := range []string{distro, target, version, arch} {
+		if !validName(v) {
+			return badRequest("distro, target, version and arch should be provided")
+		}
+	}
+	signtime, err := time.Parse(time.RFC3339, t)
+	if err != nil {
+		return badRequest("unable to parse time: " + err.Error())
+	}
+	if time.Since(signtime).Abs() > removeTimeout {
+		return badRequest("signed delete request is outdated")
+	}
+
+	tmp, err := os.MkdirTemp(r.Dir, ".remove")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	msgpath := path.Join(tmp, "packdel")
+	err = os.WriteFile(msgpath, []byte(t+owner+target), 0644)
+	if err != nil {
+		return err
+	}
+	sign, err := io.ReadAll(http.MaxBytesReader(nil, req.Body, maxSignatureSize))
+	if err != nil {
+		return badRequest("unable to read signature: " + err.Error())
+	}
+	err = os.WriteFile(msgpath+".sig", sign, 0644)
+	if err != nil {
+		return err
+	}
+
+	fpr, err := r.verify(msgpath, msgpath+".sig", email)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err = r.authorize(owner, fpr, false)
+	if err != nil {
+		return err
+	}
+
+	archs := []string{arch}
+	fn := fmt.Sprintf("%s-%s-%s.pkg.tar.zst", target, version, arch)
+	dir := path.Join(r.Dir, owner, distro, arch)
+	if _, err := os.Stat(path.Join(dir, fn)); errors.Is(err, os.ErrNotExist) {
+		fn = fmt.Sprintf("%s-%s-any.pkg.tar.zst", target, version)
+		archs, err = r.archs(owner, distro, "any")
+		if err != nil {
+			return err
+		}
+	}
+
+	var found bool
+	for _, arch := range archs {
+		dir := path.Join(r.Dir, owner, distro, arch)
+		if _, err := os.Stat(path.Join(dir, fn)); err != nil {
+			continue
+		}
+		found = true
+		err = pacman.RepoRemove(r.dbname(owner)+".db.tar.gz", []string{target}, pacman.RepoRemoveParameters{
+			Dir:    dir,
+			Stdout: io.Discard,
+			Stderr: r.Stderr,
+		})
+		if err != nil {
+			return err
+		}
+		err = errors.Join(
+			os.Remove(path.Join(dir, fn)),
+			os.Remove(path.Join(dir, fn+".sig")),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	if !found {
+		return registryError{
+			status: http.StatusNotFound,
+			msg:    "package not found: " + fn,
+		}
+	}
+	return nil
+}
+
+// Verify detached signature with GnuPG, and ensure it is made by key with
+// provided email. Returns fingerprint of primary key.
+func (r *registry) verify(file, sig, email string) (string, error) {
+	var args []string
+	if r.GpgDir != `` {
+		args = append(args, "--homedir", r.GpgDir)
+	}
+	args = append(args, "--status-fd", "1", "--verify", sig, file)
+
+	var b bytes.Buffer
+	cmd := exec.Command("gpg", args...)
+	cmd.Stdout = &b
+	err := cmd.Run()
+	if err != nil {
+		return ``, registryError{
+			status: http.StatusUnauthorized,
+			msg:    "unable to verify signature for " + email,
+		}
+	}
+	var good bool
+	var fpr string
+	for _, line := range strings.Split(b.String(), "\n") {
+		if strings.HasPrefix(line, "[GNUPG:] GOODSIG ") &&
+			strings.Contains(line, "<"+email+">") {
+			good = true
+		}
+		if fields := strings.Fields(line); len(fields) > 2 &&
+			fields[1] == "VALIDSIG" {
+			fpr = fields[len(fields)-1]
+		}
+	}
+	if !good || fpr == `` {
+		return ``, registryError{
+			status: http.StatusUnauthorized,
+			msg:    "signature does not match email " + email,
+		}
+	}
+	return fpr, nil
+}
+
+// File with fingerprints of keys, that are allowed to sign packages of owner.
+func (r *registry) keysFile(owner string) string {
+	return path.Join(r.Dir, owner, "keys")
+}
+
+// Check that key with provided fingerprint is allowed to modify packages of
+// owner. Allowed fingerprints are listed in owner's keys file, which can be
+// edited by registry administrator. With claim, signer of first push to owner
+// without keys becomes it's only allowed key. Should be called with registry
+// lock held.
+func (r *registry) authorize(owner, fpr string, claim bool) error {
+	b, err := os.ReadFile(r.keysFile(owner))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	fprs := strings.Fields(string(b))
+	for _, f := range fprs {
+		if strings.EqualFold(f, fpr) {
+			return nil
+		}
+	}
+	if len(fprs) > 0 || !claim {
+		return registryError{
+			status: http.StatusForbidden,
+			msg:    "key " + fpr + " is not allowed to modify packages of owner",
+		}
+	}
+	err = os.MkdirAll(path.Join(r.Dir, owner), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.keysFile(owner), []byte(fpr+"\n"), 0644)
+}
+
+// Serve armored public keys, that are allowed to sign packages of owner.
+// Clients import them to pacman keyring when adding database.
+func (r *registry) serveKey(w http.ResponseWriter, req *http.Request, owner string) {
+	r.mu.Lock()
+	b, err := os.ReadFile(r.keysFile(owner))
+	r.mu.Unlock()
+	fprs := strings.Fields(string(b))
+	if err != nil || len(fprs) == 0 {
+		http.NotFound(w, req)
+		return
+	}
+
+	var args []string
+	if r.GpgDir != `` {
+		args = append(args, "--homedir", r.GpgDir)
+	}
+	args = append(args, "--armor", "--export")
+	args = append(args, fprs...)
+
+	var out bytes.Buffer
+	cmd := exec.Command("gpg", args...)
+	cmd.Stdout = &out
+	cmd.Stderr = r.Stderr
+	err = cmd.Run()
+	if err != nil || out.Len() == 0 {
+		fmt.Fprintf(r.Stderr, "%s %s: unable to export keys: %v\n", req.Method, req.URL.Path, err)
+		http.Error(w, "unable to export keys", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pgp-keys")
+	w.Write(out.Bytes())
+}
+
+// Check that provided value can be safely used as path element. Names
+// starting with dot are reserved for temporary directories of registry.
+func validName(s string) bool {
+	return s != `` && !strings.HasPrefix(s, ".") && !strings.ContainsAny(s, "/\\")
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	return errors.Join(err, out.Close())
+}
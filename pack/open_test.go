@@ -0,0 +1,334 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pack
+
+import (
+	"archive/tar"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"fmnx.su/core/pack/pacman"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Replace pacman runner with fake one for duration of the test.
+func fakePacman(t *testing.T, responses ...pacman.FakeResponse) *pacman.FakeRunner {
+	t.Helper()
+	f := pacman.NewFakeRunner(responses...)
+	prev := pacman.DefaultRunner
+	pacman.DefaultRunner = f
+	t.Cleanup(func() { pacman.DefaultRunner = prev })
+	return f
+}
+
+// Write package file with provided .PKGINFO contents to directory.
+func writePackage(t *testing.T, dir, filename, pkginfo string) string {
+	t.Helper()
+	file := path.Join(dir, filename)
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw, err := zstd.NewWriter(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(zw)
+	files := []struct{ name, body string }{
+		{".PKGINFO", pkginfo},
+		{"usr/bin/pkg", "#!/bin/sh\n"},
+	}
+	for _, f := range files {
+		err = tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.body))})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = tw.Write([]byte(f.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = tw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = zw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func pkginfo(name, version, arch string) string {
+	return "pkgname = " + name + "\npkgver = " + version + "\narch = " + arch + "\n"
+}
+
+// Temporary GnuPG home with keys for provided emails.
+type testGnupg struct {
+	t    *testing.T
+	home string
+}
+
+func newTestGnupg(t *testing.T, emails ...string) *testGnupg {
+	t.Helper()
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg is not installed")
+	}
+	home, err := os.MkdirTemp(``, "pack-gpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &testGnupg{t: t, home: home}
+	t.Cleanup(func() {
+		exec.Command("gpgconf", "--homedir", home, "--kill", "gpg-agent").Run()
+		os.RemoveAll(home)
+	})
+	for _, email := range emails {
+		g.gpg("--passphrase", ``, "--quick-gen-key", "Test <"+email+">", "ed25519", "sign", "never")
+	}
+	return g
+}
+
+func (g *testGnupg) gpg(args ...string) string {
+	g.t.Helper()
+	args = append([]string{"--homedir", g.home, "--batch", "--pinentry-mode", "loopback"}, args...)
+	out, err := exec.Command("gpg", args...).Output()
+	if err != nil {
+		g.t.Fatalf("gpg %s: %v", strings.Join(args, " "), err)
+	}
+	return string(out)
+}
+
+// Create binary detached signature of file made by key with provided email.
+func (g *testGnupg) sign(file, email string) []byte {
+	g.t.Helper()
+	g.gpg("--local-user", "<"+email+">", "--yes", "--output", file+".sig", "--detach-sign", file)
+	b, err := os.ReadFile(file + ".sig")
+	if err != nil {
+		g.t.Fatal(err)
+	}
+	return b
+}
+
+func (g *testGnupg) fingerprint(email string) string {
+	g.t.Helper()
+	for _, line := range strings.Split(g.gpg("--with-colons", "--list-keys", "<"+email+">"), "\n") {
+		fields := strings.Split(line, ":")
+		if fields[0] == "fpr" {
+			return fields[9]
+		}
+	}
+	g.t.Fatal("no key for " + email)
+	return ``
+}
+
+func newTestRegistry(t *testing.T, g *testGnupg) *registry {
+	return &registry{OpenParameters: OpenParameters{
+		Stdout:   io.Discard,
+		Stderr:   io.Discard,
+		Endpoint: defaultEndpoint,
+		Dir:      t.TempDir(),
+		Name:     "localhost:8080",
+		GpgDir:   g.home,
+		MaxSize:  maxPackageSize,
+	}}
+}
+
+func pushRequest(t *testing.T, g *testGnupg, owner, file, email string) *http.Request {
+	t.Helper()
+	body, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPut, "/api/packages/"+owner+"/arch/push", strings.NewReader(string(body)))
+	req.Header.Set("filename", path.Base(file))
+	req.Header.Set("email", email)
+	req.Header.Set("distro", "archlinux")
+	req.Header.Set("sign", hex.EncodeToString(g.sign(file, email)))
+	return req
+}
+
+func removeRequest(t *testing.T, g *testGnupg, owner, target, version, arch, email string) *http.Request {
+	t.Helper()
+	now := time.Now().Format(time.RFC3339)
+	msg := path.Join(t.TempDir(), "packdel")
+	err := os.WriteFile(msg, []byte(now+owner+target), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodDelete, "/api/packages/"+owner+"/arch/remove", strings.NewReader(string(g.sign(msg, email))))
+	req.Header.Set("email", email)
+	req.Header.Set("distro", "archlinux")
+	req.Header.Set("target", target)
+	req.Header.Set("version", version)
+	req.Header.Set("arch", arch)
+	req.Header.Set("time", now)
+	return req
+}
+
+func serve(r *registry, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestOpenPushRemove(t *testing.T) {
+	g := newTestGnupg(t, "john@doe.com", "jane@doe.com")
+	fake := fakePacman(t)
+	r := newTestRegistry(t, g)
+	file := writePackage(t, t.TempDir(), "pkg-1.0-1-x86_64.pkg.tar.zst", pkginfo("pkg", "1.0-1", "x86_64"))
+
+	rec := serve(r, pushRequest(t, g, "john", file, "john@doe.com"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("push: %d %s", rec.Code, rec.Body)
+	}
+	dir := path.Join(r.Dir, "john", "archlinux", "x86_64")
+	for _, fn := range []string{"pkg-1.0-1-x86_64.pkg.tar.zst", "pkg-1.0-1-x86_64.pkg.tar.zst.sig"} {
+		if _, err := os.Stat(path.Join(dir, fn)); err != nil {
+			t.Errorf("pushed file is missing: %v", err)
+		}
+	}
+	b, err := os.ReadFile(r.keysFile("john"))
+	if err != nil || strings.TrimSpace(string(b)) != g.fingerprint("john@doe.com") {
+		t.Errorf("first push should claim owner, keys file: %q %v", b, err)
+	}
+
+	rec = serve(r, pushRequest(t, g, "john", file, "jane@doe.com"))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("push with other key: expected 403, got %d %s", rec.Code, rec.Body)
+	}
+	rec = serve(r, removeRequest(t, g, "john", "pkg", "1.0-1", "x86_64", "jane@doe.com"))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("remove with other key: expected 403, got %d %s", rec.Code, rec.Body)
+	}
+
+	rec = serve(r, removeRequest(t, g, "john", "pkg", "1.0-1", "x86_64", "john@doe.com"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("remove: %d %s", rec.Code, rec.Body)
+	}
+	if _, err := os.Stat(path.Join(dir, "pkg-1.0-1-x86_64.pkg.tar.zst")); !os.IsNotExist(err) {
+		t.Errorf("package file should be removed: %v", err)
+	}
+	rec = serve(r, removeRequest(t, g, "john", "pkg", "1.0-1", "x86_64", "john@doe.com"))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("remove of missing package: expected 404, got %d %s", rec.Code, rec.Body)
+	}
+
+	want := []string{
+		"repo-add john.localhost:8080.db.tar.gz pkg-1.0-1-x86_64.pkg.tar.zst",
+		"repo-remove john.localhost:8080.db.tar.gz pkg",
+	}
+	if got := fake.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("commands:\n  got:  %q\n  want: %q", got, want)
+	}
+}
+
+func TestOpenPushMismatch(t *testing.T) {
+	g := newTestGnupg(t, "john@doe.com")
+	fake := fakePacman(t)
+	r := newTestRegistry(t, g)
+
+	cases := []struct {
+		filename string
+		info     string
+	}{
+		{"pkg-1.0-1-x86_64.pkg.tar.zst", pkginfo("other", "1.0-1", "x86_64")},
+		{"pkg-1.0-1-x86_64.pkg.tar.zst", pkginfo("pkg", "2.0-1", "x86_64")},
+		{"pkg-1.0-1-any.pkg.tar.zst", pkginfo("pkg", "1.0-1", "x86_64")},
+	}
+	for _, c := range cases {
+		file := writePackage(t, t.TempDir(), c.filename, c.info)
+		rec := serve(r, pushRequest(t, g, "john", file, "john@doe.com"))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s with %q: expected 400, got %d %s", c.filename, c.info, rec.Code, rec.Body)
+		}
+	}
+	if len(fake.Commands()) != 0 {
+		t.Errorf("mismatched packages should not be added: %q", fake.Commands())
+	}
+}
+
+func TestOpenServeKey(t *testing.T) {
+	g := newTestGnupg(t, "john@doe.com")
+	fakePacman(t)
+	r := newTestRegistry(t, g)
+
+	rec := serve(r, httptest.NewRequest(http.MethodGet, "/api/packages/john/arch/key", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("key of unknown owner: expected 404, got %d", rec.Code)
+	}
+
+	file := writePackage(t, t.TempDir(), "pkg-1.0-1-any.pkg.tar.zst", pkginfo("pkg", "1.0-1", "any"))
+	rec = serve(r, pushRequest(t, g, "john", file, "john@doe.com"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("push: %d %s", rec.Code, rec.Body)
+	}
+
+	rec = serve(r, httptest.NewRequest(http.MethodGet, "/api/packages/john/arch/key", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "BEGIN PGP PUBLIC KEY BLOCK") {
+		t.Errorf("key: %d %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/pgp-keys" {
+		t.Errorf("unexpected content type: %s", ct)
+	}
+}
+
+func TestOpenHiddenOwner(t *testing.T) {
+	g := newTestGnupg(t)
+	r := newTestRegistry(t, g)
+	err := os.MkdirAll(path.Join(r.Dir, ".push123", "archlinux", "x86_64"), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(path.Join(r.Dir, ".push123", "archlinux", "x86_64", "pkg.db"), nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, url := range []string{
+		"/api/packages/.push123/arch/archlinux/x86_64/pkg.db",
+		"/api/packages/.push123/arch/key",
+		"/api/packages/../arch/key",
+	} {
+		rec := serve(r, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", url, rec.Code)
+		}
+	}
+}
+
+func TestValidName(t *testing.T) {
+	cases := []struct {
+		name string
+		want bool
+	}{
+		{"john", true},
+		{"pkg-1.0-1-x86_64.pkg.tar.zst", true},
+		{"1:1.0-1", true},
+		{``, false},
+		{".", false},
+		{"..", false},
+		{".push123", false},
+		{".remove", false},
+		{"a/b", false},
+		{"a\\b", false},
+	}
+	for _, c := range cases {
+		if got := validName(c.name); got != c.want {
+			t.Errorf("validName(%q) = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -6,6 +6,7 @@
 package pack
 
 import (
+	"context"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -14,8 +15,12 @@ import (
 	"os"
 	"path"
 	"strings"
+	"sync"
+	"time"
 
 	"fmnx.su/core/pack/msgs"
+	"fmnx.su/core/pack/pacman"
+	"fmnx.su/core/pack/pacman/pkgfile"
 	"github.com/mitchellh/ioprogress"
 )
 
@@ -31,22 +36,43 @@ type PushParameters struct {
 	Insecure bool
 	// Custom distribution for which package is built.
 	Distro string
+	// API rootpath of registry, owner is inserted before last element.
+	Endpoint string
+	// Fingerprint of key used to identify pusher, default key if empty.
+	Key string
+	// Timeout for connecting to registry and waiting for it's response.
+	Timeout time.Duration
+	// Amount of retries for uploads failed with server errors or dropped
+	// connections.
+	Retries int
+	// Amount of packages uploaded simultaneously.
+	Jobs int
 }
 
 func pushdefault() *PushParameters {
 	return &PushParameters{
 		Directory: "/var/cache/pacman/pkg",
 		Distro:    "archlinux",
+		Endpoint:  defaultEndpoint,
+		Timeout:   DefaultTimeout,
+		Retries:   DefaultRetries,
+		Jobs:      DefaultJobs,
 	}
 }
 
 // Push your package to registry.
 func Push(args []string, prms ...PushParameters) error {
+	return PushContext(context.Background(), args, prms...)
+}
+
+// PushContext is like Push, but uploads are interrupted when context is
+// canceled. Packages, that were not pushed, are shown in summary.
+func PushContext(ctx context.Context, args []string, prms ...PushParameters) error {
 	p := formOptions(prms, pushdefault)
 
 	msgs.Amsg(p.Stdout, "Preparing pushed packages")
 
-	email, err := gnupgEmail()
+	email, err := gnupgEmail(p.Key)
 	if err != nil {
 		return err
 	}
@@ -65,78 +91,272 @@ func Push(args []string, prms ...PushParameters) error {
 	msgs.Smsg(p.Stdout, "Preparing package metadata", 3, 3)
 
 	msgs.Amsg(p.Stdout, "Pushing packages")
+	return pushAll(ctx, p, mds, email)
+}
+
+// Push packages concurrently with limited amount of jobs, failed package
+// does not prevent other packages from being pushed. For multiple packages
+// summary is shown at the end.
+func pushAll(ctx context.Context, p *PushParameters, mds []PackageMetadata, email string) error {
+	jobs := p.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	loaders := msgs.NewLoaders(p.Stdout)
+	errs := make([]error, len(mds))
+	queue := make(chan int)
+
+	var wg sync.WaitGroup
+	for j := 0; j < jobs; j++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				md := mds[i]
+				draw := loaders.Loader(&msgs.LoaderParameters{
+					Current: i + 1,
+					Total:   len(mds),
+					Msg: fmt.Sprintf(
+						"Pushing %s (%s) to %s...", md.Name, md.Arch,
+						path.Join(md.Registry, md.Owner),
+					),
+					Output: p.Stdout,
+				})
+				err := push(ctx, *p, md, email, draw)
+				if err != nil {
+					errs[i] = fmt.Errorf("unable to push %s (%s) to %s: %w",
+						md.Name, md.Arch, path.Join(md.Registry, md.Owner), err)
+					msgs.Emit(p.Stdout, msgs.Event{
+						Step:     "push",
+						Package:  md.Name,
+						Version:  md.Version,
+						Registry: path.Join(md.Registry, md.Owner),
+						Status:   msgs.StatusError,
+						Error:    errs[i].Error(),
+					})
+				}
+			}
+		}()
+	}
+	for i := range mds {
+		if ctx.Err() != nil {
+			errs[i] = fmt.Errorf("unable to push %s (%s) to %s: %w",
+				mds[i].Name, mds[i].Arch, path.Join(mds[i].Registry, mds[i].Owner), ctx.Err())
+			continue
+		}
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	var pushed, failed []PackageMetadata
 	for i, md := range mds {
-		err = push(*p, md, email, i+1, len(mds))
-		if err != nil {
-			return err
+		if errs[i] != nil {
+			failed = append(failed, md)
+			continue
 		}
+		pushed = append(pushed, md)
 	}
-	return nil
+	if len(mds) > 1 {
+		pushSummary(p.Stdout, p, pushed, failed)
+	}
+	return errors.Join(errs...)
+}
+
+// Show which packages were pushed and which failed, and command that can be
+// used to push only failed package files with the same options.
+func pushSummary(w io.Writer, p *PushParameters, pushed, failed []PackageMetadata) {
+	if msgs.JSON {
+		msgs.Emit(w, msgs.Event{
+			Step:   "push summary",
+			Status: msgs.StatusDone,
+			Result: map[string][]PackageMetadata{
+				"pushed": pushed,
+				"failed": failed,
+			},
+		})
+		return
+	}
+	msgs.Amsg(w, "Push summary")
+	for _, md := range pushed {
+		fmt.Fprintf(w, "pushed: %s %s (%s)\n", md.Name, md.Version, md.Arch)
+	}
+	for _, md := range failed {
+		fmt.Fprintf(w, "failed: %s %s (%s)\n", md.Name, md.Version, md.Arch)
+	}
+	if len(failed) > 0 {
+		fmt.Fprintf(w, "push failed packages with: %s\n", retryCommand(p, failed))
+	}
+}
+
+// Get push command for provided packages, options that differ from defaults
+// are preserved. Targets contain exact file names, so that only failed
+// architectures and versions are pushed again.
+func retryCommand(p *PushParameters, mds []PackageMetadata) string {
+	d := pushdefault()
+	args := []string{"pack", "-P"}
+	if p.Directory != d.Directory {
+		args = append(args, "-d", p.Directory)
+	}
+	if p.Insecure {
+		args = append(args, "-w")
+	}
+	if p.Distro != d.Distro {
+		args = append(args, "--distro", p.Distro)
+	}
+	if p.Endpoint != d.Endpoint {
+		args = append(args, "--endpoint", p.Endpoint)
+	}
+	if p.Key != `` {
+		args = append(args, "--key", p.Key)
+	}
+	for _, md := range mds {
+		args = append(args, path.Join(md.Registry, md.Owner, md.FileName))
+	}
+	return strings.Join(args, " ")
+}
+
+// Emit event about successfully pushed package.
+func emitPushed(w io.Writer, md PackageMetadata) {
+	msgs.Emit(w, msgs.Event{
+		Step:     "push",
+		Package:  md.Name,
+		Version:  md.Version,
+		Registry: path.Join(md.Registry, md.Owner),
+		Status:   msgs.StatusDone,
+	})
 }
 
 // This function will be used to get email from user's GnuPG identitry.
-func gnupgEmail() (string, error) {
-	gnupgident, err := gnuPGIdentity()
+func gnupgEmail(fingerprint string) (string, error) {
+	key, err := loadKey(false, fingerprint)
 	if err != nil {
 		return ``, err
 	}
-	return strings.ReplaceAll(strings.Split(gnupgident, "<")[1], ">", ""), nil
+	email := key.Email()
+	if email == `` {
+		return ``, errors.New("no email in key identity: " + key.Identity())
+	}
+	return email, nil
 }
 
 type PackageMetadata struct {
-	Name     string
-	FileName string
-	Registry string
-	Owner    string
+	Name     string `json:"name"`
+	FileName string `json:"filename"`
+	Version  string `json:"version"`
+	Arch     string `json:"arch"`
+	Registry string `json:"registry"`
+	Owner    string `json:"owner,omitempty"`
 }
 
 // Collect metadata about packages, ensure all packages could be pushed.
+// Target can reference package name, in that case latest version from cache
+// is pushed for each architecture, or exact package file name.
 func prepareMetadata(dir string, filenames, pkgs []string) ([]PackageMetadata, error) {
 	var mds []PackageMetadata
 	for _, pkg := range pkgs {
 		md := PackageMetadata{}
 
-		splt := strings.Split(pkg, "/")
-		switch len(splt) {
-		case 1:
+		target, name := path.Split(pkg)
+		if target == `` {
 			return nil, errors.New("no registry to push: " + pkg)
-		case 2:
-			md.Registry = splt[0]
-			md.Name = splt[1]
-		case 3:
-			md.Registry = splt[0]
-			md.Owner = splt[1]
-			md.Name = splt[2]
 		}
+		md.Registry, md.Owner = splitRegistry(target)
+		md.Name = name
 
-		var err error
-		md.FileName, err = getLastverCachedPkgFile(md.Name, filenames)
-		if err != nil {
-			return nil, err
+		fns := []string{name}
+		if !strings.HasSuffix(name, ".pkg.tar.zst") {
+			var err error
+			fns, err = getLastverCachedPkgFiles(md.Name, filenames)
+			if err != nil {
+				return nil, err
+			}
 		}
 
-		mds = append(mds, md)
+		for _, fn := range fns {
+			info, err := checkPkgInfo(path.Join(dir, fn))
+			if err != nil {
+				return nil, err
+			}
+			md.FileName = fn
+			md.Name = info.Name
+			md.Version = info.Version
+			md.Arch = info.Arch
+			mds = append(mds, md)
+		}
 	}
 	return mds, nil
 }
 
-// Get lastet package from list based on package name.
-func getLastverCachedPkgFile(pkg string, files []string) (string, error) {
-	for i := len(files) - 1; i >= 0; i-- {
-		filename := files[i]
+// Split push target into registry and optional owner.
+func splitRegistry(target string) (string, string) {
+	registry, owner, _ := strings.Cut(strings.Trim(target, "/"), "/")
+	return registry, owner
+}
+
+// Get latest version of package from list based on package name, versions are
+// compared with pacman's vercmp. All files with that version are returned,
+// one for each architecture found in cache.
+func getLastverCachedPkgFiles(pkg string, files []string) ([]string, error) {
+	var version string
+	var rez []string
+	for _, filename := range files {
 		if !strings.HasPrefix(filename, pkg) {
 			continue
 		}
 		pkgsplt := strings.Split(filename, "-")
 		if len(pkgsplt) < 4 {
-			return ``, errors.New("not valid package file name: " + filename)
+			return nil, errors.New("not valid package file name: " + filename)
 		}
 		if !(strings.Join(pkgsplt[:len(pkgsplt)-3], "-") == pkg) {
 			continue
 		}
-		return filename, nil
+		ver := strings.Join(pkgsplt[len(pkgsplt)-3:len(pkgsplt)-1], "-")
+		switch {
+		case version == `` || pacman.Vercmp(ver, version) > 0:
+			version = ver
+			rez = []string{filename}
+		case pacman.Vercmp(ver, version) == 0:
+			rez = append(rez, filename)
+		}
+	}
+	if len(rez) == 0 {
+		return nil, errors.New("cannot find package in cache: " + pkg)
+	}
+	return rez, nil
+}
+
+// Split package file name (name-pkgver-pkgrel-arch.pkg.tar.zst) into package
+// name, version and architecture.
+func splitPkgFile(filename string) (string, string, string, bool) {
+	trimmed, ok := strings.CutSuffix(filename, ".pkg.tar.zst")
+	splt := strings.Split(trimmed, "-")
+	if !ok || len(splt) < 4 {
+		return ``, ``, ``, false
+	}
+	n := len(splt)
+	return strings.Join(splt[:n-3], "-"), splt[n-3] + "-" + splt[n-2], splt[n-1], true
+}
+
+// Read metadata of package file and ensure that name, version and
+// architecture from .PKGINFO match package file name.
+func checkPkgInfo(file string) (*pkgfile.Info, error) {
+	filename := path.Base(file)
+	name, version, arch, ok := splitPkgFile(filename)
+	if !ok {
+		return nil, errors.New("not valid package file name: " + filename)
+	}
+	info, err := pkgfile.OpenInfo(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read package %s: %w", filename, err)
+	}
+	if info.Name != name || info.Version != version || info.Arch != arch {
+		return nil, fmt.Errorf(
+			"package file %s contains %s-%s-%s",
+			filename, info.Name, info.Version, info.Arch,
+		)
 	}
-	return ``, errors.New("cannot find package in cache: " + pkg)
+	return info, nil
 }
 
 // List file names in provided cache directory.
@@ -156,64 +376,64 @@ func listPkgFilenames(dir string) ([]string, error) {
 }
 
 // This function pushes package to registry via http/https.
-func push(pp PushParameters, md PackageMetadata, email string, i, t int) error {
+func push(ctx context.Context, pp PushParameters, md PackageMetadata, email string, draw ioprogress.DrawFunc) error {
 	pkgpath := path.Join(pp.Directory, md.FileName)
-	packagefile, err := os.Open(pkgpath)
-	if err != nil {
-		return err
-	}
 	pkgInfo, err := os.Stat(pkgpath)
 	if err != nil {
 		return err
 	}
 
-	prfx := "https://"
-	if pp.Insecure {
-		prfx = "http://"
+	// Package file is reopened for each upload attempt.
+	body := func() (io.ReadCloser, error) {
+		packagefile, err := os.Open(pkgpath)
+		if err != nil {
+			return nil, err
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{
+			Reader: &ioprogress.Reader{
+				Reader:   packagefile,
+				Size:     pkgInfo.Size(),
+				DrawFunc: draw,
+			},
+			Closer: packagefile,
+		}, nil
 	}
 
-	req, err := http.NewRequest(
-		http.MethodPut,
-		prfx+path.Join(md.Registry, "api/packages", md.Owner, "arch/push"),
-		&ioprogress.Reader{
-			Reader: packagefile,
-			Size:   pkgInfo.Size(),
-			DrawFunc: msgs.Loader(&msgs.LoaderParameters{
-				Current: i,
-				Total:   t,
-				Msg: fmt.Sprintf(
-					"Pushing %s to %s...", md.Name,
-					path.Join(md.Registry, md.Owner),
-				),
-				Output: pp.Stdout,
-			}),
-		},
-	)
+	sig, err := os.ReadFile(pkgpath + ".sig")
 	if err != nil {
 		return err
 	}
 
-	f, err := os.ReadFile(pkgpath + ".sig")
+	rc, err := body()
 	if err != nil {
 		return err
 	}
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPut,
+		registryURL(pp.Insecure, md.Registry, pp.Endpoint, md.Owner, "push"),
+		rc,
+	)
+	if err != nil {
+		rc.Close()
+		return err
+	}
+	req.GetBody = body
+	req.ContentLength = pkgInfo.Size()
 
 	req.Header.Add("filename", md.FileName)
 	req.Header.Add("email", email)
-	req.Header.Add("sign", hex.EncodeToString(f))
+	req.Header.Add("sign", hex.EncodeToString(sig))
 	req.Header.Add("distro", pp.Distro)
 
-	var client http.Client
-	resp, err := client.Do(req)
+	resp, err := send(req, pp.Timeout, pp.Retries)
 	if err != nil {
 		return err
 	}
-	if resp.StatusCode != http.StatusOK {
-		b, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return errors.Join(err, errors.New(resp.Status))
-		}
-		return fmt.Errorf("%s %s", resp.Status, string(b))
-	}
+	resp.Body.Close()
+	emitPushed(pp.Stdout, md)
 	return nil
 }
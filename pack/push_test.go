@@ -0,0 +1,179 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pack
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitPkgFile(t *testing.T) {
+	cases := []struct {
+		filename            string
+		name, version, arch string
+		ok                  bool
+	}{
+		{"pack-0.6.2-1-x86_64.pkg.tar.zst", "pack", "0.6.2-1", "x86_64", true},
+		{"go-tools-1:0.15.0-1-any.pkg.tar.zst", "go-tools", "1:0.15.0-1", "any", true},
+		{"pack-0.6.2-1.pkg.tar.zst", ``, ``, ``, false},
+		{"pack-0.6.2-1-x86_64.pkg.tar.xz", ``, ``, ``, false},
+	}
+	for _, c := range cases {
+		name, version, arch, ok := splitPkgFile(c.filename)
+		if name != c.name || version != c.version || arch != c.arch || ok != c.ok {
+			t.Errorf("%s: got %q %q %q %v", c.filename, name, version, arch, ok)
+		}
+	}
+}
+
+func TestPrepareMetadata(t *testing.T) {
+	dir := t.TempDir()
+	writePackage(t, dir, "pack-0.6.1-1-x86_64.pkg.tar.zst", pkginfo("pack", "0.6.1-1", "x86_64"))
+	writePackage(t, dir, "pack-0.6.2-1-x86_64.pkg.tar.zst", pkginfo("pack", "0.6.2-1", "x86_64"))
+	writePackage(t, dir, "pack-0.6.2-1-aarch64.pkg.tar.zst", pkginfo("pack", "0.6.2-1", "aarch64"))
+	filenames, err := listPkgFilenames(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mds, err := prepareMetadata(dir, filenames, []string{"fmnx.su/john/pack"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PackageMetadata{
+		{Name: "pack", FileName: "pack-0.6.2-1-aarch64.pkg.tar.zst", Version: "0.6.2-1", Arch: "aarch64", Registry: "fmnx.su", Owner: "john"},
+		{Name: "pack", FileName: "pack-0.6.2-1-x86_64.pkg.tar.zst", Version: "0.6.2-1", Arch: "x86_64", Registry: "fmnx.su", Owner: "john"},
+	}
+	if !reflect.DeepEqual(mds, want) {
+		t.Errorf("metadata:\n  got:  %+v\n  want: %+v", mds, want)
+	}
+
+	mds, err = prepareMetadata(dir, filenames, []string{"fmnx.su/john/pack-0.6.1-1-x86_64.pkg.tar.zst"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []PackageMetadata{
+		{Name: "pack", FileName: "pack-0.6.1-1-x86_64.pkg.tar.zst", Version: "0.6.1-1", Arch: "x86_64", Registry: "fmnx.su", Owner: "john"},
+	}
+	if !reflect.DeepEqual(mds, want) {
+		t.Errorf("file target:\n  got:  %+v\n  want: %+v", mds, want)
+	}
+}
+
+func TestRetryCommand(t *testing.T) {
+	failed := []PackageMetadata{
+		{Name: "pack", FileName: "pack-0.6.2-1-x86_64.pkg.tar.zst", Registry: "fmnx.su", Owner: "john"},
+		{Name: "pack", FileName: "pack-0.6.2-1-aarch64.pkg.tar.zst", Registry: "mirror.org"},
+	}
+	files := " fmnx.su/john/pack-0.6.2-1-x86_64.pkg.tar.zst mirror.org/pack-0.6.2-1-aarch64.pkg.tar.zst"
+	cases := []struct {
+		prms PushParameters
+		want string
+	}{
+		{*pushdefault(), "pack -P" + files},
+		{
+			PushParameters{
+				Directory: "/tmp/pkg",
+				Insecure:  true,
+				Distro:    "archlinux",
+				Endpoint:  "/api/arch",
+				Key:       "ABCD",
+			},
+			"pack -P -d /tmp/pkg -w --endpoint /api/arch --key ABCD" + files,
+		},
+		{
+			PushParameters{
+				Directory: "/var/cache/pacman/pkg",
+				Distro:    "artix",
+				Endpoint:  defaultEndpoint,
+			},
+			"pack -P --distro artix" + files,
+		},
+	}
+	for _, c := range cases {
+		if got := retryCommand(&c.prms, failed); got != c.want {
+			t.Errorf("got:  %s\nwant: %s", got, c.want)
+		}
+	}
+}
+
+// Renamed package files should not be pushed under name, version or
+// architecture from file name.
+func TestPrepareMetadataMismatch(t *testing.T) {
+	cases := []struct {
+		filename string
+		info     string
+	}{
+		{"pack-1.0-1-x86_64.pkg.tar.zst", pkginfo("ainst", "1.0-1", "x86_64")},
+		{"pack-1.0-1-x86_64.pkg.tar.zst", pkginfo("pack", "0.9-1", "x86_64")},
+		{"pack-1.0-1-x86_64.pkg.tar.zst", pkginfo("pack", "1.0-2", "x86_64")},
+		{"pack-1.0-1-x86_64.pkg.tar.zst", pkginfo("pack", "1.0-1", "any")},
+	}
+	for _, c := range cases {
+		dir := t.TempDir()
+		writePackage(t, dir, c.filename, c.info)
+		_, err := prepareMetadata(dir, []string{c.filename}, []string{"fmnx.su/pack"})
+		if err == nil || !strings.Contains(err.Error(), "package file "+c.filename+" contains") {
+			t.Errorf("%q: expected mismatch error, got %v", c.info, err)
+		}
+	}
+}
+
+func TestGetLastverCachedPkgFiles(t *testing.T) {
+	cases := []struct {
+		pkg   string
+		files []string
+		want  []string
+	}{
+		{
+			pkg: "pack",
+			files: []string{
+				"pack-0.6.10-1-x86_64.pkg.tar.zst",
+				"pack-0.6.9-1-x86_64.pkg.tar.zst",
+				"pack-git-1.0-1-x86_64.pkg.tar.zst",
+				"pack-0.6.10-1-aarch64.pkg.tar.zst",
+			},
+			want: []string{"pack-0.6.10-1-x86_64.pkg.tar.zst", "pack-0.6.10-1-aarch64.pkg.tar.zst"},
+		},
+		{
+			pkg: "pack",
+			files: []string{
+				"pack-2.0-1-x86_64.pkg.tar.zst",
+				"pack-1:1.0-1-x86_64.pkg.tar.zst",
+			},
+			want: []string{"pack-1:1.0-1-x86_64.pkg.tar.zst"},
+		},
+		{
+			// Versions equal for vercmp are treated as the same version.
+			pkg: "pack",
+			files: []string{
+				"pack-0:1.0-1-x86_64.pkg.tar.zst",
+				"pack-1.0-1-aarch64.pkg.tar.zst",
+				"pack-01.0-1-any.pkg.tar.zst",
+			},
+			want: []string{
+				"pack-0:1.0-1-x86_64.pkg.tar.zst",
+				"pack-1.0-1-aarch64.pkg.tar.zst",
+				"pack-01.0-1-any.pkg.tar.zst",
+			},
+		},
+	}
+	for _, c := range cases {
+		got, err := getLastverCachedPkgFiles(c.pkg, c.files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q:\n  got:  %q\n  want: %q", c.files, got, c.want)
+		}
+	}
+
+	_, err := getLastverCachedPkgFiles("ainst", []string{"pack-1.0-1-any.pkg.tar.zst"})
+	if err == nil {
+		t.Error("expected error for package missing in cache")
+	}
+}
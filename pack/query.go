@@ -0,0 +1,192 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"fmnx.su/core/pack/msgs"
+	"fmnx.su/core/pack/pacman"
+	"fmnx.su/core/pack/pacman/syncdb"
+)
+
+// Parameters for querying local and remote packages.
+type QueryParameters struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	Stdin  io.Reader
+
+	// View package information (-ii for backup files).
+	Info []bool
+	// List the files owned by the queried package.
+	List []bool
+	// List outdated packages.
+	Outdated bool
+	// Distribution of remote database.
+	Distro string
+	// Architecture of remote database.
+	Arch string
+	// Use HTTP instead of https for remote queries.
+	Insecure bool
+	// API rootpath of registry, owner is inserted before last element.
+	Endpoint string
+}
+
+func querydefault() *QueryParameters {
+	return &QueryParameters{
+		Stdout:   os.Stdout,
+		Stderr:   os.Stderr,
+		Stdin:    os.Stdin,
+		Distro:   "archlinux",
+		Arch:     "x86_64",
+		Endpoint: defaultEndpoint,
+	}
+}
+
+// Query local packages with pacman, or list packages available in remote
+// registry for arguements formatted as registry/owner(/package).
+func Query(args []string, prms ...QueryParameters) error {
+	return QueryContext(context.Background(), args, prms...)
+}
+
+// QueryContext is like Query, but pacman and requests to registries are
+// interrupted when context is canceled.
+func QueryContext(ctx context.Context, args []string, prms ...QueryParameters) error {
+	p := formOptions(prms, querydefault)
+
+	local, remote := splitRemote(args)
+
+	if len(local) > 0 || len(remote) == 0 {
+		err := queryLocal(ctx, p, local)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, target := range remote {
+		err := queryRemote(ctx, p, target)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Query local packages with pacman. In JSON mode pacman output is parsed and
+// emitted as result.
+func queryLocal(ctx context.Context, p *QueryParameters, pkgs []string) error {
+	if !msgs.JSON {
+		return pacman.QueryContext(ctx, pkgs, pacman.QueryParameters{
+			Info:    p.Info,
+			List:    p.List,
+			Upgrade: p.Outdated,
+			Stdout:  p.Stdout,
+			Stderr:  p.Stderr,
+			Stdin:   p.Stdin,
+		})
+	}
+
+	var rez any
+	var err error
+	switch {
+	case p.Outdated:
+		rez, err = pacman.OutdatedContext(ctx)
+	case len(p.Info) > 0:
+		var infos []*pacman.PackageInfoFull
+		for _, pkg := range pkgs {
+			info, err := pacman.InfoContext(ctx, pkg)
+			if err != nil {
+				return err
+			}
+			infos = append(infos, info)
+		}
+		rez = infos
+	default:
+		rez, err = pacman.QueryListContext(ctx, pkgs)
+	}
+	if err != nil {
+		return err
+	}
+	msgs.Emit(p.Stdout, msgs.Event{
+		Step:   "query",
+		Status: msgs.StatusDone,
+		Result: rez,
+	})
+	return nil
+}
+
+// Download registry database for owner and print packages matching target.
+func queryRemote(ctx context.Context, p *QueryParameters, target string) error {
+	splt := strings.Split(target, "/")
+	if len(splt) < 2 || len(splt) > 3 {
+		return errors.New("remote target should be registry/owner(/package): " + target)
+	}
+	registry, owner := splt[0], splt[1]
+	var name string
+	if len(splt) == 3 {
+		name = splt[2]
+	}
+
+	database := owner + "." + registry
+	pkgs, err := remotePackages(ctx, p, registry, owner, database)
+	if err != nil {
+		return err
+	}
+
+	var found []syncdb.Package
+	for _, pkg := range pkgs {
+		if name != `` && pkg.Name != name {
+			continue
+		}
+		found = append(found, pkg)
+		if msgs.JSON {
+			continue
+		}
+		signer, err := pkg.Signer()
+		if err != nil {
+			signer = "invalid signature: " + err.Error()
+		}
+		if signer == `` {
+			signer = "not signed"
+		}
+		fmt.Fprintf(p.Stdout, "%s/%s %s\n    %s\n    Signature: %s\n",
+			database, pkg.Name, pkg.Version, pkg.Desc, signer)
+	}
+	if len(found) == 0 && name != `` {
+		return fmt.Errorf("package %s not found in %s", name, database)
+	}
+	msgs.Emit(p.Stdout, msgs.Event{
+		Step:     "query",
+		Package:  name,
+		Registry: registry + "/" + owner,
+		Status:   msgs.StatusDone,
+		Result:   found,
+	})
+	return nil
+}
+
+// Download and parse pacman database of registry owner.
+func remotePackages(ctx context.Context, p *QueryParameters, registry, owner, database string) ([]syncdb.Package, error) {
+	url := registryURL(p.Insecure, registry, p.Endpoint, owner, p.Distro, p.Arch, database+".db")
+	msgs.Amsg(p.Stdout, "Loading database "+url)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := send(req, DefaultTimeout, DefaultRetries)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return syncdb.Read(resp.Body)
+}
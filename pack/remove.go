@@ -7,18 +7,18 @@ package pack
 
 import (
 	"bytes"
-	"errors"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
-	"os/exec"
 	"path"
 	"strings"
 	"time"
 
 	"fmnx.su/core/pack/msgs"
 	"fmnx.su/core/pack/pacman"
+	"fmnx.su/core/pack/pgp"
 )
 
 type RemoveParameters struct {
@@ -40,31 +40,42 @@ type RemoveParameters struct {
 	Insecure bool
 	// Set custom architectures for deletion.
 	Arch string
+	// API rootpath of registry, owner is inserted before last element.
+	Endpoint string
+	// Fingerprint of key used to sign remote deletions, default key if empty.
+	Key string
 }
 
 func removeDefault() *RemoveParameters {
 	return &RemoveParameters{
-		Stdout: os.Stdout,
-		Stderr: os.Stderr,
-		Stdin:  os.Stdin,
-		Distro: "archlinux",
-		Arch:   "x86_64",
+		Stdout:   os.Stdout,
+		Stderr:   os.Stderr,
+		Stdin:    os.Stdin,
+		Distro:   "archlinux",
+		Arch:     "x86_64",
+		Endpoint: defaultEndpoint,
 	}
 }
 
 func Remove(args []string, prms ...RemoveParameters) error {
+	return RemoveContext(context.Background(), args, prms...)
+}
+
+// RemoveContext is like Remove, but pacman and requests to registries are
+// interrupted when context is canceled.
+func RemoveContext(ctx context.Context, args []string, prms ...RemoveParameters) error {
 	p := formOptions(prms, removeDefault)
 
-	local, remote := splitRemoved(args)
+	local, remote := splitRemote(args)
 
 	if len(local) > 0 {
-		err := pacman.RemoveList(local, pacman.RemoveParameters{
+		err := pacman.RemoveListContext(ctx, local, pacman.RemoveParameters{
 			Sudo:        true,
 			NoConfirm:   !p.Confirm,
 			Recursive:   !p.Norecursive,
 			WithConfigs: !p.Nocfgs,
 			Cascade:     p.Cascade,
-			Stdout:      p.Stdout,
+			Stdout:      cmdOutput(p.Stdout, p.Stderr),
 			Stderr:      p.Stderr,
 			Stdin:       p.Stdin,
 		})
@@ -74,17 +85,18 @@ func Remove(args []string, prms ...RemoveParameters) error {
 	}
 
 	if len(remote) > 0 {
-		email, err := gnupgEmail()
+		key, err := unlockKey(p.Key, p.Stdin, p.Stderr)
 		if err != nil {
 			return err
 		}
+		email := key.Email()
 		if p.Distro == "" {
 			p.Distro = "archlinux"
 		}
 		msgs.Amsg(p.Stdout, "Removing remote packages as "+email)
 		for i, pkg := range remote {
 			msgs.Smsg(p.Stdout, "Removing "+pkg, i+1, len(remote))
-			err := rmRemote(p, pkg, email)
+			err := rmRemote(ctx, p, pkg, key)
 			if err != nil {
 				return err
 			}
@@ -94,8 +106,8 @@ func Remove(args []string, prms ...RemoveParameters) error {
 	return nil
 }
 
-// Splits packages that will be removed locally and on remote.
-func splitRemoved(pkgs []string) ([]string, []string) {
+// Splits packages into local and remote ones.
+func splitRemote(pkgs []string) ([]string, []string) {
 	var local []string
 	var remote []string
 	for _, pkg := range pkgs {
@@ -128,7 +140,7 @@ func splitVer(pkg string) (string, string, error) {
 }
 
 // Function that will be used to remove remote package.
-func rmRemote(p *RemoveParameters, pkg, email string) error {
+func rmRemote(ctx context.Context, p *RemoveParameters, pkg string, key *pgp.Key) error {
 	t := time.Now().Format(time.RFC3339)
 
 	remote, owner, target, version, err := splitPkg(pkg)
@@ -136,63 +148,39 @@ func rmRemote(p *RemoveParameters, pkg, email string) error {
 		return err
 	}
 
-	err = os.WriteFile("packdel", []byte(t+owner+target), os.ModePerm)
-	if err != nil {
-		return err
-	}
-
-	var errbuf bytes.Buffer
-	cmd := exec.Command("gpg", "--detach-sign", "packdel")
-	cmd.Stdout = p.Stdout
-	cmd.Stderr = &errbuf
-	err = cmd.Run()
-	if err != nil {
-		return errors.Join(errors.New(errbuf.String()), err)
-	}
-
-	err = os.RemoveAll("packdel")
-	if err != nil {
-		return err
-	}
-
-	signature, err := os.Open("packdel.sig")
+	signature, err := key.DetachSign(strings.NewReader(t + owner + target))
 	if err != nil {
 		return err
 	}
 
-	prfx := "https://"
-	if p.Insecure {
-		prfx = "http://"
-	}
-
-	req, err := http.NewRequest(
+	req, err := http.NewRequestWithContext(
+		ctx,
 		http.MethodDelete,
-		prfx+path.Join(remote, "api/packages", owner, "arch/remove"),
-		signature,
+		registryURL(p.Insecure, remote, p.Endpoint, owner, "remove"),
+		bytes.NewReader(signature),
 	)
 	if err != nil {
 		return err
 	}
 
-	req.Header.Add("email", email)
+	req.Header.Add("email", key.Email())
 	req.Header.Add("distro", p.Distro)
 	req.Header.Add("target", target)
 	req.Header.Add("time", t)
 	req.Header.Add("version", version)
 	req.Header.Add("arch", p.Arch)
 
-	var client http.Client
-	resp, err := client.Do(req)
-	err = errors.Join(os.RemoveAll("packdel.sig"), err)
+	resp, err := send(req, DefaultTimeout, DefaultRetries)
 	if err != nil {
 		return err
 	}
-	if resp.StatusCode != http.StatusOK {
-		b, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return errors.Join(err, errors.New(resp.Status))
-		}
-		return fmt.Errorf("%s %s", resp.Status, string(b))
-	}
+	resp.Body.Close()
+	msgs.Emit(p.Stdout, msgs.Event{
+		Step:     "remove",
+		Package:  target,
+		Version:  version,
+		Registry: path.Join(remote, owner),
+		Status:   msgs.StatusDone,
+	})
 	return nil
 }
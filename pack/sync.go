@@ -6,15 +6,18 @@
 package pack
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
-	"os/exec"
+	"path"
 	"strings"
 
 	"fmnx.su/core/pack/msgs"
 	"fmnx.su/core/pack/pacman"
+	"fmnx.su/core/pack/pacman/conf"
 )
 
 type SyncParameters struct {
@@ -32,34 +35,56 @@ type SyncParameters struct {
 	Force bool
 	// Use HTTP instead of https
 	Insecure bool
+	// API rootpath of registry, owner is inserted before last element.
+	Endpoint string
+	// Distribution used in server URLs of added databases, detected from
+	// /etc/os-release if empty.
+	Distro string
+	// Architecture used in server URLs of added databases, detected from
+	// pacman.conf or uname if empty.
+	Arch string
+	// URL of public key of registry owner, imported to pacman keyring when
+	// database is added. Key endpoint of registry is used if empty, in that
+	// case fingerprints should be confirmed by user even in quick mode.
+	KeyURL string
+	// Do not import key of registry owner when database is added, packages
+	// should be signed by key already trusted in pacman keyring.
+	NoKey bool
+	// Add packages installed with exact version (pkg@version) to IgnorePkg
+	// in pacman.conf, so that they are not upgraded.
+	Pin bool
 }
 
 func syncdefault() *SyncParameters {
 	return &SyncParameters{
-		Quick:   true,
-		Refresh: []bool{true},
-		Stdout:  os.Stdout,
-		Stderr:  os.Stderr,
-		Stdin:   os.Stdin,
+		Quick:    true,
+		Refresh:  []bool{true},
+		Endpoint: defaultEndpoint,
+		Stdout:   os.Stdout,
+		Stderr:   os.Stderr,
+		Stdin:    os.Stdin,
 	}
 }
 
 // Syncronize provided packages with provided parameters.
 func Sync(args []string, prms ...SyncParameters) error {
-	p := formOptions(prms, syncdefault)
+	return SyncContext(context.Background(), args, prms...)
+}
 
-	var err error
-	var conf *string
-	var pkgs []string
+// SyncContext is like Sync, but pacman is interrupted when context is
+// canceled. Changes of pacman.conf are rolled back and sync databases of
+// added registries are removed if sync fails or is interrupted.
+func SyncContext(ctx context.Context, args []string, prms ...SyncParameters) error {
+	p := formOptions(prms, syncdefault)
 
 	if len(args) == 0 {
-		return pacman.SyncList(pkgs, pacman.SyncParameters{
+		return pacman.SyncListContext(ctx, nil, pacman.SyncParameters{
 			Sudo:      true,
 			Needed:    !p.Force,
 			NoConfirm: p.Quick,
 			Refresh:   p.Refresh,
 			Upgrade:   p.Upgrade,
-			Stdout:    p.Stdout,
+			Stdout:    cmdOutput(p.Stdout, p.Stderr),
 			Stderr:    p.Stderr,
 			Stdin:     p.Stdin,
 		})
@@ -67,68 +92,193 @@ func Sync(args []string, prms ...SyncParameters) error {
 
 	msgs.Amsg(p.Stdout, "Syncronizing packages")
 
-	msgs.Smsg(p.Stdout, "Adding missing databases to pacman.conf", 1, 2)
-	conf, err = addMissingDatabases(args, p.Insecure)
+	err := detectHost(ctx, &p.Distro, &p.Arch)
 	if err != nil {
 		return err
 	}
 
-	msgs.Smsg(p.Stdout, "Preparing packages to sync format", 2, 2)
-	pkgs = formatPackages(args)
+	added, modifies, err := modifiesConf(p, args)
+	if err != nil {
+		return err
+	}
+	if !modifies {
+		return syncTargets(ctx, p, args)
+	}
+	tx, err := begin()
+	if err != nil {
+		return err
+	}
+	err = tx.track(added...)
+	if err != nil {
+		return errors.Join(err, tx.commit())
+	}
+	err = syncTargets(ctx, p, args)
+	if err != nil {
+		return errors.Join(err, ctx.Err(), tx.rollback())
+	}
+	return tx.commit()
+}
 
-	err = pacman.SyncList(pkgs, pacman.SyncParameters{
-		Sudo:      true,
-		Needed:    !p.Force,
-		NoConfirm: p.Quick,
-		Refresh:   p.Refresh,
-		Upgrade:   p.Upgrade,
-		Stdout:    p.Stdout,
-		Stderr:    p.Stderr,
-		Stdin:     p.Stdin,
-	})
+// Add missing databases and install provided targets.
+func syncTargets(ctx context.Context, p *SyncParameters, args []string) error {
+	pkgs, versioned, err := splitVersioned(args)
 	if err != nil {
-		return errors.Join(err, writeconf(*conf))
+		return err
 	}
-	return nil
+	steps := 2
+	if len(versioned) > 0 {
+		steps = 3
+	}
+
+	msgs.Smsg(p.Stdout, "Adding missing databases to pacman.conf", 1, steps)
+	err = addMissingDatabases(ctx, args, p)
+	if err != nil {
+		return err
+	}
+
+	msgs.Smsg(p.Stdout, "Preparing packages to sync format", 2, steps)
+	pkgs = formatPackages(pkgs)
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if len(pkgs) > 0 {
+		err = pacman.SyncListContext(ctx, pkgs, pacman.SyncParameters{
+			Sudo:      true,
+			Needed:    !p.Force,
+			NoConfirm: p.Quick,
+			Refresh:   p.Refresh,
+			Upgrade:   p.Upgrade,
+			Stdout:    cmdOutput(p.Stdout, p.Stderr),
+			Stderr:    p.Stderr,
+			Stdin:     p.Stdin,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, pkg := range pkgs {
+		msgs.Emit(p.Stdout, msgs.Event{
+			Step:    "sync",
+			Package: pkg,
+			Status:  msgs.StatusDone,
+		})
+	}
+
+	if len(versioned) == 0 {
+		return nil
+	}
+	msgs.Smsg(p.Stdout, "Installing exact package versions", 3, steps)
+	return installVersions(ctx, p, versioned)
 }
 
 // Iterate over packages, check wether package database is present, if not
-// add new database to pacman.conf. Return previous version of pacman.conf.
-func addMissingDatabases(pkgs []string, insecure bool) (*string, error) {
-	protocol := "https"
-	if insecure {
-		protocol = "http"
-	}
-	f, err := os.ReadFile("/etc/pacman.conf")
+// import owner's key and add new database to pacman.conf.
+func addMissingDatabases(ctx context.Context, pkgs []string, p *SyncParameters) error {
+	c, err := conf.Open(conf.Path)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	missing := missingDatabases(c, pkgs)
+	for _, db := range missing {
+		err = addDatabase(ctx, c, p, db[0], db[1])
+		if err != nil {
+			return err
+		}
 	}
-	conf := string(f)
+	if len(missing) == 0 {
+		return nil
+	}
+	return c.Save(conf.Path, true)
+}
+
+// Get registries and owners of packages, which databases are not present in
+// pacman.conf. Owner is empty for packages provided as registry/pkg.
+func missingDatabases(c *conf.Config, pkgs []string) [][2]string {
+	var missing [][2]string
 	for _, pkg := range pkgs {
 		splt := strings.Split(pkg, "/")
 		switch len(splt) {
 		case 2:
-			if strings.Contains(conf, splt[0]+"/api/packages/arch") {
-				continue
-			}
-			addConfDatabase(protocol, splt[0], splt[0], "")
+			splt[1] = ``
 		case 3:
-			if strings.Contains(conf, splt[0]+"/api/packages/arch/"+splt[1]) {
-				continue
-			}
-			addConfDatabase(protocol, splt[1]+"."+splt[0], splt[0], "/"+splt[1])
+		default:
+			continue
 		}
+		db := [2]string{splt[0], splt[1]}
+		if c.Section(databaseName(db[0], db[1])) != nil || containsDatabase(missing, db) {
+			continue
+		}
+		missing = append(missing, db)
 	}
-	return &conf, nil
+	return missing
 }
 
-// Simple function to add database to pacman.conf.
-func addConfDatabase(protocol, database, domain, owner string) error {
-	const confroot = "\n[%s]\nServer = %s://%s/api/packages/%s/arch/%s/%s\n"
-	os := "archlinux"
-	tmpl := fmt.Sprintf(confroot, database, protocol, domain, owner, os, "x86_64")
-	command := "cat <<EOF >> /etc/pacman.conf" + tmpl + "EOF"
-	return call(exec.Command("sudo", "bash", "-c", command))
+func containsDatabase(dbs [][2]string, db [2]string) bool {
+	for _, d := range dbs {
+		if d == db {
+			return true
+		}
+	}
+	return false
+}
+
+// Check wether sync of provided targets modifies pacman.conf, in that case
+// changes should be made in transaction. Names of databases, that will be
+// added, are also returned.
+func modifiesConf(p *SyncParameters, args []string) ([]string, bool, error) {
+	_, versioned, err := splitVersioned(args)
+	if err != nil {
+		return nil, false, err
+	}
+	c, err := conf.Open(conf.Path)
+	if err != nil {
+		return nil, false, err
+	}
+	var added []string
+	for _, db := range missingDatabases(c, args) {
+		added = append(added, databaseName(db[0], db[1]))
+	}
+	return added, len(added) > 0 || (p.Pin && len(versioned) > 0), nil
+}
+
+// Name of pacman database for registry and owner, owner can be empty.
+func databaseName(registry, owner string) string {
+	if owner == `` {
+		return registry
+	}
+	return owner + "." + registry
+}
+
+// Import key of owner and add database for registry and owner to pacman.conf.
+func addDatabase(ctx context.Context, c *conf.Config, p *SyncParameters, registry, owner string) error {
+	database := databaseName(registry, owner)
+	keyurl := p.KeyURL
+	if keyurl == `` {
+		keyurl = registryURL(p.Insecure, registry, p.Endpoint, owner, "key")
+	}
+	if !p.NoKey {
+		err := importKey(ctx, p, database, keyurl, p.KeyURL == ``)
+		if err != nil {
+			return err
+		}
+	}
+	url := registryURL(p.Insecure, registry, p.Endpoint, owner)
+	addConfDatabase(c, url, database, p.Distro, p.Arch)
+	return nil
+}
+
+// Simple function to add database to pacman.conf, url should contain protocol,
+// registry, endpoint and owner. Section is marked as added by pack.
+// Credentials are never written to pacman.conf, as it is readable by all
+// users, pacman reads them from netrc of root user.
+func addConfDatabase(c *conf.Config, url, database, distro, arch string) {
+	c.SetRepository(conf.Repository{
+		Name:     database,
+		Servers:  []string{url + "/" + distro + "/" + arch},
+		SigLevel: defaultSigLevel,
+		Comment:  packComment,
+	})
 }
 
 // Format packages to pre-sync format.
@@ -148,10 +298,156 @@ func formatPackages(pkgs []string) []string {
 	return out
 }
 
-// Overwrite pacman.conf with provided string.
-func writeconf(s string) error {
-	return call(exec.Command(
-		"sudo", "bash", "-c",
-		"cat <<EOF > /etc/pacman.conf\n"+s+"EOF",
-	))
+// Separate packages with exact version (registry/owner/pkg@version) from
+// packages, that should be syncronized with pacman.
+func splitVersioned(args []string) ([]string, []string, error) {
+	var pkgs, versioned []string
+	for _, arg := range args {
+		if !strings.Contains(arg, "@") {
+			pkgs = append(pkgs, arg)
+			continue
+		}
+		if n := strings.Count(arg, "/"); n < 1 || n > 2 {
+			return nil, nil, fmt.Errorf("version can be set only for registry packages: %s", arg)
+		}
+		versioned = append(versioned, arg)
+	}
+	return pkgs, versioned, nil
+}
+
+// Download exact versions of packages from registries, verify signatures
+// with pacman keyring and install them with pacman -U.
+func installVersions(ctx context.Context, p *SyncParameters, pkgs []string) error {
+	dir, err := os.MkdirTemp(``, "pack-sync")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	out := cmdOutput(p.Stdout, p.Stderr)
+	var files, names, versions []string
+	for _, pkg := range pkgs {
+		registry, owner, name, version, err := splitPkg(pkg)
+		if err != nil {
+			return err
+		}
+		file, err := downloadVersion(ctx, p, dir, registry, owner, name, version)
+		if err != nil {
+			return err
+		}
+		err = pacman.KeyVerifyContext(ctx, file+".sig", file, pacman.KeyParameters{
+			Sudo:   true,
+			Stdout: out,
+			Stderr: p.Stderr,
+			Stdin:  p.Stdin,
+		})
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+		names = append(names, name)
+		versions = append(versions, version)
+	}
+
+	err = pacman.UpgradeListContext(ctx, files, pacman.UpgradeParameters{
+		Sudo:      true,
+		Needed:    !p.Force,
+		NoConfirm: p.Quick,
+		Stdout:    out,
+		Stderr:    p.Stderr,
+		Stdin:     p.Stdin,
+	})
+	if err != nil {
+		return err
+	}
+	if p.Pin {
+		err = pin(names)
+		if err != nil {
+			return err
+		}
+	}
+	for i, name := range names {
+		msgs.Emit(p.Stdout, msgs.Event{
+			Step:    "sync",
+			Package: name,
+			Version: versions[i],
+			Status:  msgs.StatusDone,
+		})
+	}
+	return nil
+}
+
+// Download package file with provided version and it's signature from
+// registry to dir. Package for host architecture is looked up first, then
+// package built for any architecture.
+func downloadVersion(ctx context.Context, p *SyncParameters, dir, registry, owner, name, version string) (string, error) {
+	var err error
+	for _, arch := range []string{p.Arch, "any"} {
+		fn := fmt.Sprintf("%s-%s-%s.pkg.tar.zst", name, version, arch)
+		url := registryURL(p.Insecure, registry, p.Endpoint, owner, p.Distro, p.Arch, fn)
+		file := path.Join(dir, fn)
+		err = download(ctx, url, file)
+		if notFound(err) {
+			continue
+		}
+		if err != nil {
+			return ``, fmt.Errorf("unable to download %s: %w", fn, err)
+		}
+		err = download(ctx, url+".sig", file+".sig")
+		if err != nil {
+			return ``, fmt.Errorf("unable to download signature for %s: %w", fn, err)
+		}
+		return file, nil
+	}
+	return ``, fmt.Errorf("package %s@%s is not found in %s: %w", name, version, registry, err)
+}
+
+// Download file from registry.
+func download(ctx context.Context, url, file string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := send(req, DefaultTimeout, DefaultRetries)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, resp.Body)
+	return errors.Join(err, f.Close())
+}
+
+// Add packages to IgnorePkg in pacman.conf, so that pacman does not upgrade
+// them.
+func pin(names []string) error {
+	c, err := conf.Open(conf.Path)
+	if err != nil {
+		return err
+	}
+	s := c.Section("options")
+	if s == nil {
+		return errors.New("unable to pin packages, no options in " + conf.Path)
+	}
+	ignored := s.Values("IgnorePkg")
+	var changed bool
+	for _, name := range names {
+		if !contains(ignored, name) {
+			ignored = append(ignored, name)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	s.Set("IgnorePkg", strings.Join(ignored, " "))
+	return c.Save(conf.Path, true)
+}
+
+// Overwrite pacman.conf with provided contents.
+func writeconf(b []byte) error {
+	return conf.WriteFile(conf.Path, b, true)
 }
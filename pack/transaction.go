@@ -0,0 +1,193 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pack
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+
+	"fmnx.su/core/pack/msgs"
+	"fmnx.su/core/pack/pacman/conf"
+)
+
+// Directory with sync databases downloaded by pacman.
+const syncDir = "/var/lib/pacman/sync"
+
+// Backup of pacman.conf written before it is modified. Backup is removed
+// when transaction ends, leftover backup means pack was killed in the middle
+// of transaction and can be restored with 'pack -U --restore-conf'.
+const confBackup = conf.Path + ".pack-backup"
+
+// Transaction for changes of pacman.conf and sync databases. Original
+// pacman.conf is saved when transaction begins, sync database files are
+// saved when databases are tracked, and both are restored on rollback.
+type transaction struct {
+	// Original contents of pacman.conf.
+	conf []byte
+	// Temporary directory with copies of sync database files.
+	dir string
+	// Files of tracked databases in sync directory.
+	files []string
+	// Tracked files, that were present when they were saved.
+	saved map[string]os.FileInfo
+}
+
+// Begin transaction, backup of pacman.conf is written. Nothing is saved in
+// dry run mode, as files are not modified. Leftover backup equal to current
+// pacman.conf is stale (sync was killed before changing pacman.conf), so it
+// is overwritten.
+func begin() (*transaction, error) {
+	b, err := os.ReadFile(conf.Path)
+	if err != nil {
+		return nil, err
+	}
+	backup, err := os.ReadFile(confBackup)
+	switch {
+	case err == nil && !bytes.Equal(backup, b):
+		return nil, fmt.Errorf(
+			"found %s left by interrupted sync, restore it with 'pack -U --restore-conf' or remove it",
+			confBackup,
+		)
+	case err != nil && !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	}
+	t := &transaction{conf: b, saved: map[string]os.FileInfo{}}
+	if dryrun != nil {
+		return t, nil
+	}
+	err = conf.WriteFile(confBackup, b, true)
+	if err != nil {
+		return nil, fmt.Errorf("unable to backup pacman.conf: %w", err)
+	}
+	return t, nil
+}
+
+// Sync database files of provided database in sync directory.
+func databaseFiles(name string) []string {
+	return []string{
+		path.Join(syncDir, name+".db"),
+		path.Join(syncDir, name+".db.sig"),
+		path.Join(syncDir, name+".files"),
+	}
+}
+
+// Save files of databases, that will be added or removed in transaction.
+// Other databases are not touched on rollback.
+func (t *transaction) track(names ...string) error {
+	if dryrun != nil {
+		return nil
+	}
+	for _, name := range names {
+		for _, file := range databaseFiles(name) {
+			err := t.save(file)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Copy file to temporary directory, missing file is only remembered, so
+// that it is removed on rollback.
+func (t *transaction) save(file string) error {
+	t.files = append(t.files, file)
+	info, err := os.Stat(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if t.dir == `` {
+		t.dir, err = os.MkdirTemp(``, "pack-syncdb")
+		if err != nil {
+			return err
+		}
+	}
+	err = copyFile(file, path.Join(t.dir, path.Base(file)))
+	if err != nil {
+		return err
+	}
+	t.saved[file] = info
+	return nil
+}
+
+// Restore pacman.conf and tracked sync databases, changed after transaction
+// began. Backup is kept if pacman.conf could not be restored. Nothing was
+// changed in dry run mode, so nothing is restored.
+func (t *transaction) rollback() error {
+	if dryrun != nil {
+		return t.commit()
+	}
+	err := writeconf(t.conf)
+	if err != nil {
+		return fmt.Errorf("unable to restore pacman.conf, backup is in %s: %w", confBackup, err)
+	}
+	return errors.Join(t.restoreDatabases(), t.commit())
+}
+
+// Remove files of tracked databases created during transaction and restore
+// changed or removed ones.
+func (t *transaction) restoreDatabases() error {
+	var created []string
+	for _, file := range t.files {
+		cur, err := os.Stat(file)
+		info, ok := t.saved[file]
+		switch {
+		case !ok && err == nil:
+			created = append(created, file)
+			continue
+		case !ok:
+			continue
+		case err == nil && cur.Size() == info.Size() && cur.ModTime().Equal(info.ModTime()):
+			continue
+		}
+		err = call(exec.Command(
+			"sudo", "install", "-m", "0644",
+			path.Join(t.dir, path.Base(file)), file,
+		))
+		if err != nil {
+			return err
+		}
+	}
+	if len(created) > 0 {
+		return call(exec.Command("sudo", append([]string{"rm", "-f"}, created...)...))
+	}
+	return nil
+}
+
+// End transaction, backup and copies of sync databases are removed.
+func (t *transaction) commit() error {
+	if t.dir != `` {
+		os.RemoveAll(t.dir)
+	}
+	if dryrun != nil {
+		return nil
+	}
+	return call(exec.Command("sudo", "rm", "-f", confBackup))
+}
+
+// Restore pacman.conf from backup left by interrupted sync.
+func restoreConf(p *UtilParameters) error {
+	b, err := os.ReadFile(confBackup)
+	if errors.Is(err, os.ErrNotExist) {
+		return errors.New("no backup of pacman.conf found in " + confBackup)
+	}
+	if err != nil {
+		return err
+	}
+	msgs.Amsg(p.Stdout, "Restoring pacman.conf from "+confBackup)
+	err = writeconf(b)
+	if err != nil {
+		return err
+	}
+	return call(exec.Command("sudo", "rm", "-f", confBackup))
+}
@@ -0,0 +1,56 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pack
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"fmnx.su/core/pack/pacman"
+	"fmnx.su/core/pack/pacman/conf"
+)
+
+// Enable dry run mode for single test.
+func dryRun(t *testing.T) *bytes.Buffer {
+	var b bytes.Buffer
+	DryRun(&b)
+	t.Cleanup(func() {
+		dryrun = nil
+		pacman.DryRun = nil
+		conf.DryRun = nil
+	})
+	return &b
+}
+
+// Nothing is changed in dry run mode, so rollback should neither write
+// pacman.conf nor touch sync databases.
+func TestRollbackDryRun(t *testing.T) {
+	out := dryRun(t)
+	tx := &transaction{conf: []byte("[options]\n")}
+	err := tx.track("john.fmnx.su")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tx.rollback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("unexpected dry run output:\n%s", out)
+	}
+}
+
+func TestDatabaseFiles(t *testing.T) {
+	want := []string{
+		"/var/lib/pacman/sync/john.fmnx.su.db",
+		"/var/lib/pacman/sync/john.fmnx.su.db.sig",
+		"/var/lib/pacman/sync/john.fmnx.su.files",
+	}
+	if got := databaseFiles("john.fmnx.su"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -34,6 +34,8 @@ type UtilParameters struct {
 	Flutter bool
 	// Generate go cli utility template.
 	Gocli bool
+	// Restore pacman.conf from backup left by interrupted sync.
+	RestoreConf bool
 }
 
 func utildefault() *UtilParameters {
@@ -60,6 +62,8 @@ func Util(args []string, prms ...UtilParameters) error {
 		return fluttertemplate()
 	case p.Gocli:
 		return goclitemplate()
+	case p.RestoreConf:
+		return restoreConf(p)
 	}
 	return errors.New("specify command options, run 'pack -Uh'")
 }
@@ -71,7 +75,7 @@ func setpkgr(pkgr string, p *UtilParameters) error {
 	cmd.Stdout = p.Stdout
 	cmd.Stderr = p.Stderr
 	cmd.Stdin = p.Stdin
-	return cmd.Run()
+	return execute(cmd)
 }
 
 // Recieve gpg key.
@@ -80,7 +84,7 @@ func recv(id string, p *UtilParameters) error {
 	cmd.Stdout = p.Stdout
 	cmd.Stderr = p.Stderr
 	cmd.Stdin = p.Stdin
-	return cmd.Run()
+	return execute(cmd)
 }
 
 // Generate new GPG key with user input and etc.
@@ -94,9 +98,11 @@ func generate(p *UtilParameters) error {
 
 // Return armored public key string from GnuPG.
 func armored(o io.Writer) error {
-	cmd := exec.Command("gpg", "--armor", "--export")
-	cmd.Stdout = o
-	return call(cmd)
+	key, err := loadKey(false, ``)
+	if err != nil {
+		return err
+	}
+	return key.Armor(o)
 }
 
 // Function generates project template for flutter desktop application based on
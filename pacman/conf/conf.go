@@ -0,0 +1,295 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package conf
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Default location of pacman configuration file.
+const Path = "/etc/pacman.conf"
+
+// Parsed pacman configuration file. Lines before first section header are
+// stored in section with empty name.
+type Config struct {
+	Sections []*Section
+}
+
+// Section of configuration file, either [options] or repository.
+type Section struct {
+	Name string
+	// Comment on section header line, without '#' prefix.
+	Comment string
+	Lines   []Line
+}
+
+// Single line of configuration file. Comments and empty lines have empty key
+// and are kept as they are. Options without value (like Color) have empty
+// value.
+type Line struct {
+	Key   string
+	Value string
+	// Original text of line, used to write unchanged lines back as they were.
+	raw string
+}
+
+// Repository section parameters that can be added or updated with
+// SetRepository.
+type Repository struct {
+	Name     string
+	Servers  []string
+	SigLevel string
+	// Comment written on section header line, can be used to mark sections
+	// added by program.
+	Comment string
+}
+
+// Open and parse pacman configuration file.
+func Open(file string) (*Config, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse pacman configuration from reader.
+func Parse(r io.Reader) (*Config, error) {
+	c := &Config{Sections: []*Section{{}}}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		raw := sc.Text()
+		text, comment, _ := strings.Cut(raw, "#")
+		trimmed := strings.TrimSpace(text)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			c.Sections = append(c.Sections, &Section{
+				Name:    strings.Trim(trimmed, "[]"),
+				Comment: strings.TrimSpace(comment),
+			})
+			continue
+		}
+		s := c.Sections[len(c.Sections)-1]
+		s.Lines = append(s.Lines, parseLine(raw))
+	}
+	return c, sc.Err()
+}
+
+func parseLine(raw string) Line {
+	text, _, _ := strings.Cut(raw, "#")
+	key, value, _ := strings.Cut(text, "=")
+	return Line{
+		Key:   strings.TrimSpace(key),
+		Value: strings.TrimSpace(value),
+		raw:   raw,
+	}
+}
+
+// Get configuration file contents.
+func (c *Config) Bytes() []byte {
+	var b bytes.Buffer
+	for _, s := range c.Sections {
+		switch {
+		case s.Name != `` && s.Comment != ``:
+			b.WriteString("[" + s.Name + "] # " + s.Comment + "\n")
+		case s.Name != ``:
+			b.WriteString("[" + s.Name + "]\n")
+		}
+		for _, l := range s.Lines {
+			b.WriteString(l.String() + "\n")
+		}
+	}
+	return b.Bytes()
+}
+
+// Get section by name, returns nil if section is not found.
+func (c *Config) Section(name string) *Section {
+	for _, s := range c.Sections {
+		if s.Name == name && s.Name != `` {
+			return s
+		}
+	}
+	return nil
+}
+
+// Get all repository sections in order they appear in configuration.
+func (c *Config) Repositories() []*Section {
+	var rez []*Section
+	for _, s := range c.Sections {
+		if s.Name != `` && s.Name != "options" {
+			rez = append(rez, s)
+		}
+	}
+	return rez
+}
+
+// Add repository section to the end of configuration, or update servers and
+// signature level of existing one. Returns affected section.
+func (c *Config) SetRepository(r Repository) *Section {
+	s := c.Section(r.Name)
+	if s == nil {
+		last := c.Sections[len(c.Sections)-1]
+		n := len(last.Lines)
+		if n == 0 || last.Lines[n-1].String() != `` {
+			last.Lines = append(last.Lines, Line{})
+		}
+		s = &Section{Name: r.Name}
+		c.Sections = append(c.Sections, s)
+	}
+	if r.Comment != `` {
+		s.Comment = r.Comment
+	}
+	if r.SigLevel != `` {
+		s.Set("SigLevel", r.SigLevel)
+	}
+	if len(r.Servers) > 0 {
+		s.Delete("Server")
+		for _, srv := range r.Servers {
+			s.Add("Server", srv)
+		}
+	}
+	return s
+}
+
+// Remove repository section from configuration. Returns false if section
+// is not found.
+func (c *Config) RemoveRepository(name string) bool {
+	for i, s := range c.Sections {
+		if s.Name == name && s.Name != `` && s.Name != "options" {
+			c.Sections = append(c.Sections[:i], c.Sections[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Save configuration to provided file, see WriteFile.
+func (c *Config) Save(file string, sudo bool) error {
+	return WriteFile(file, c.Bytes(), sudo)
+}
+
+// Get first value for provided key, empty string if key is not found.
+func (s *Section) Get(key string) string {
+	for _, l := range s.Lines {
+		if l.Key == key {
+			return l.Value
+		}
+	}
+	return ``
+}
+
+// Check wether section contains provided key.
+func (s *Section) Has(key string) bool {
+	for _, l := range s.Lines {
+		if l.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Get all values for provided key, values separated with spaces (like
+// HoldPkg or IgnorePkg) are splitted.
+func (s *Section) Values(key string) []string {
+	var rez []string
+	for _, l := range s.Lines {
+		if l.Key == key {
+			rez = append(rez, strings.Fields(l.Value)...)
+		}
+	}
+	return rez
+}
+
+// Set value for provided key. First occurence is replaced and others are
+// removed, new option is added after last option of section.
+func (s *Section) Set(key, value string) {
+	for i, l := range s.Lines {
+		if l.Key == key {
+			s.Lines[i] = Line{Key: key, Value: value}
+			s.Lines = append(s.Lines[:i+1], deleteKey(s.Lines[i+1:], key)...)
+			return
+		}
+	}
+	s.Add(key, value)
+}
+
+// Add option with provided key and value after last option of section.
+func (s *Section) Add(key, value string) {
+	i := len(s.Lines)
+	for i > 0 && s.Lines[i-1].Key == `` {
+		i--
+	}
+	s.Lines = append(s.Lines[:i], append([]Line{{Key: key, Value: value}}, s.Lines[i:]...)...)
+}
+
+// Delete all options with provided key.
+func (s *Section) Delete(key string) {
+	s.Lines = deleteKey(s.Lines, key)
+}
+
+func deleteKey(lines []Line, key string) []Line {
+	var rez []Line
+	for _, l := range lines {
+		if l.Key != key {
+			rez = append(rez, l)
+		}
+	}
+	return rez
+}
+
+// Get files included in section with Include directive.
+func (s *Section) Includes() []string {
+	return s.Values("Include")
+}
+
+// Get servers of repository section, including servers from files referenced
+// with Include directives.
+func (s *Section) Servers() ([]string, error) {
+	var rez []string
+	for _, l := range s.Lines {
+		switch l.Key {
+		case "Server":
+			rez = append(rez, l.Value)
+		case "Include":
+			files, err := filepath.Glob(l.Value)
+			if err != nil {
+				return nil, err
+			}
+			for _, file := range files {
+				inc, err := Open(file)
+				if err != nil {
+					return nil, err
+				}
+				for _, is := range inc.Sections {
+					srvs, err := is.Servers()
+					if err != nil {
+						return nil, err
+					}
+					rez = append(rez, srvs...)
+				}
+			}
+		}
+	}
+	return rez, nil
+}
+
+// Text representation of line.
+func (l Line) String() string {
+	switch {
+	case l.raw != ``:
+		return l.raw
+	case l.Key == ``:
+		return ``
+	case l.Value == ``:
+		return l.Key
+	}
+	return l.Key + " = " + l.Value
+}
@@ -0,0 +1,225 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sample = `#
+# /etc/pacman.conf
+#
+[options]
+#RootDir     = /
+HoldPkg     = pacman glibc
+Architecture = auto
+IgnorePkg   = pack   # pinned by hand
+Color
+ParallelDownloads = 5
+
+SigLevel    = Required DatabaseOptional
+
+# The testing repositories are disabled by default.
+#[core-testing]
+#Include = /etc/pacman.d/mirrorlist
+
+[core]
+Include = /etc/pacman.d/mirrorlist
+
+[john.fmnx.su] # added by pack
+SigLevel = Required DatabaseOptional
+Server = https://fmnx.su/api/packages/john/arch/archlinux/x86_64
+`
+
+// Parse, edit and write configuration, comments and formatting of lines
+// not touched by edit should be preserved.
+func TestRoundTrip(t *testing.T) {
+	cases := []struct {
+		name string
+		edit func(c *Config)
+		want string
+	}{
+		{
+			name: "unchanged",
+			edit: func(c *Config) {},
+			want: sample,
+		},
+		{
+			name: "add repository",
+			edit: func(c *Config) {
+				c.SetRepository(Repository{
+					Name:     "jane.fmnx.su",
+					Servers:  []string{"https://fmnx.su/api/packages/jane/arch/archlinux/x86_64"},
+					SigLevel: "Required DatabaseOptional",
+					Comment:  "added by pack",
+				})
+			},
+			want: sample + `
+[jane.fmnx.su] # added by pack
+SigLevel = Required DatabaseOptional
+Server = https://fmnx.su/api/packages/jane/arch/archlinux/x86_64
+`,
+		},
+		{
+			name: "update repository",
+			edit: func(c *Config) {
+				c.SetRepository(Repository{
+					Name:    "john.fmnx.su",
+					Servers: []string{"https://a.su/john", "https://b.su/john"},
+				})
+			},
+			want: strings.Replace(sample,
+				"Server = https://fmnx.su/api/packages/john/arch/archlinux/x86_64\n",
+				"Server = https://a.su/john\nServer = https://b.su/john\n", 1),
+		},
+		{
+			name: "remove repository",
+			edit: func(c *Config) {
+				if !c.RemoveRepository("john.fmnx.su") {
+					t.Error("repository is not removed")
+				}
+				if c.RemoveRepository("options") {
+					t.Error("options should not be removed")
+				}
+			},
+			want: strings.Split(sample, "[john.fmnx.su]")[0],
+		},
+		{
+			name: "set option",
+			edit: func(c *Config) {
+				c.Section("options").Set("IgnorePkg", "pack ainst")
+			},
+			want: strings.Replace(sample,
+				"IgnorePkg   = pack   # pinned by hand\n",
+				"IgnorePkg = pack ainst\n", 1),
+		},
+		{
+			name: "add option before trailing empty lines",
+			edit: func(c *Config) {
+				c.Section("core").Add("Server", "https://mirror.org/core")
+				c.Section("options").Delete("Color")
+			},
+			want: strings.NewReplacer(
+				"Include = /etc/pacman.d/mirrorlist\n\n[john",
+				"Include = /etc/pacman.d/mirrorlist\nServer = https://mirror.org/core\n\n[john",
+				"Color\n", ``,
+			).Replace(sample),
+		},
+	}
+	for _, c := range cases {
+		cfg, err := Parse(strings.NewReader(sample))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.edit(cfg)
+		if got := string(cfg.Bytes()); got != c.want {
+			t.Errorf("%s:\n--- got:\n%s\n--- want:\n%s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestSectionValues(t *testing.T) {
+	c, err := Parse(strings.NewReader(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := c.Section("options")
+	cases := []struct {
+		key  string
+		get  string
+		vals []string
+		has  bool
+	}{
+		{"HoldPkg", "pacman glibc", []string{"pacman", "glibc"}, true},
+		{"IgnorePkg", "pack", []string{"pack"}, true},
+		{"Color", ``, nil, true},
+		{"RootDir", ``, nil, false},
+	}
+	for _, tc := range cases {
+		if got := opts.Get(tc.key); got != tc.get {
+			t.Errorf("Get(%s) = %q, want %q", tc.key, got, tc.get)
+		}
+		if got := opts.Values(tc.key); !reflect.DeepEqual(got, tc.vals) {
+			t.Errorf("Values(%s) = %q, want %q", tc.key, got, tc.vals)
+		}
+		if got := opts.Has(tc.key); got != tc.has {
+			t.Errorf("Has(%s) = %v, want %v", tc.key, got, tc.has)
+		}
+	}
+
+	var names []string
+	for _, s := range c.Repositories() {
+		names = append(names, s.Name)
+	}
+	if want := []string{"core", "john.fmnx.su"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("repositories: got %q, want %q", names, want)
+	}
+	if s := c.Section("john.fmnx.su"); s == nil || s.Comment != "added by pack" {
+		t.Errorf("unexpected section: %+v", s)
+	}
+}
+
+func TestServersInclude(t *testing.T) {
+	dir := t.TempDir()
+	mirrorlist := filepath.Join(dir, "mirrorlist")
+	err := os.WriteFile(mirrorlist, []byte("# mirrors\nServer = https://a.org/$repo\n#Server = https://b.org/$repo\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := Parse(strings.NewReader("[core]\nServer = https://c.org/core\nInclude = " + mirrorlist + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srvs, err := c.Section("core").Servers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"https://c.org/core", "https://a.org/$repo"}; !reflect.DeepEqual(srvs, want) {
+		t.Errorf("servers: got %q, want %q", srvs, want)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "pacman.conf")
+	c, err := Parse(strings.NewReader(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Save(file, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(written.Bytes()); got != sample {
+		t.Errorf("written file differs:\n%s", got)
+	}
+	fi, err := os.Stat(file)
+	if err != nil || fi.Mode().Perm() != 0644 {
+		t.Errorf("unexpected file mode: %v %v", fi.Mode(), err)
+	}
+
+	var hooked string
+	DryRun = func(file string, data []byte) error {
+		hooked = file
+		return nil
+	}
+	defer func() { DryRun = nil }()
+	err = WriteFile(file, []byte("changed"), true)
+	if err != nil || hooked != file {
+		t.Errorf("dry run hook is not called: %v", err)
+	}
+	b, _ := os.ReadFile(file)
+	if string(b) != sample {
+		t.Error("file should not be changed in dry run mode")
+	}
+}
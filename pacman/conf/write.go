@@ -0,0 +1,75 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package conf
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Hook, that is called instead of writing files, can be used to print
+// changes in dry run mode. Files are written as is if hook is nil.
+var DryRun func(file string, data []byte) error
+
+// Atomically replace file with provided data. Data is written to temporary
+// file in the same directory, which is renamed afterwards, so file is never
+// left partially written. With sudo temporary file is moved with root
+// privileges, which is required for files like /etc/pacman.conf.
+func WriteFile(file string, data []byte, sudo bool) error {
+	if DryRun != nil {
+		return DryRun(file, data)
+	}
+	if !sudo {
+		return writeFile(filepath.Dir(file), file, data)
+	}
+
+	tmp, err := os.MkdirTemp("", "pack")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, filepath.Base(file))
+	err = os.WriteFile(src, data, 0644)
+	if err != nil {
+		return err
+	}
+
+	dst := file + ".pack"
+	err = sudoRun("install", "-m", "0644", src, dst)
+	if err != nil {
+		return err
+	}
+	return sudoRun("mv", "-f", dst, file)
+}
+
+func writeFile(dir, file string, data []byte) error {
+	f, err := os.CreateTemp(dir, filepath.Base(file)+".pack")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	err = errors.Join(err, f.Chmod(0644), f.Close())
+	if err != nil {
+		return errors.Join(err, os.Remove(f.Name()))
+	}
+	return os.Rename(f.Name(), file)
+}
+
+func sudoRun(args ...string) error {
+	var b bytes.Buffer
+	cmd := exec.Command("sudo", args...)
+	cmd.Stderr = &b
+	err := cmd.Run()
+	if err != nil && b.Len() > 0 {
+		return errors.New(strings.TrimSpace(b.String()))
+	}
+	return err
+}
@@ -0,0 +1,194 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pacman
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Kinds of pacman and makepkg failures, that can be checked with errors.Is.
+var (
+	ErrTargetNotFound = errors.New("target not found")
+	ErrDBLocked       = errors.New("unable to lock database")
+	ErrConflict       = errors.New("conflicting files or packages")
+	ErrSignature      = errors.New("invalid or missing signature")
+	ErrDependency     = errors.New("unable to satisfy dependencies")
+)
+
+// Patterns of error lines in command output, that identify kind of failure.
+// Only lines with 'error: ' and '==> ERROR: ' prefixes are matched, so that
+// package names and descriptions in regular output do not affect the kind.
+var errorKinds = []struct {
+	kind    error
+	markers []*regexp.Regexp
+}{
+	{ErrDBLocked, markers(
+		`unable to lock database`,
+	)},
+	{ErrTargetNotFound, markers(
+		`target not found`,
+		`repository ".*" was not found`,
+	)},
+	{ErrConflict, markers(
+		`conflicting files`,
+		`conflicting dependencies`,
+		`unresolvable package conflicts`,
+	)},
+	{ErrSignature, markers(
+		`invalid or corrupted package \(PGP signature\)`,
+		`signature from ".*" is unknown trust`,
+		`missing required signature`,
+		`One or more PGP signatures could not be verified`,
+	)},
+	{ErrDependency, markers(
+		`could not satisfy dependencies`,
+		`Could not resolve all dependencies`,
+	)},
+}
+
+func markers(patterns ...string) []*regexp.Regexp {
+	var rez []*regexp.Regexp
+	for _, p := range patterns {
+		rez = append(rez, regexp.MustCompile(p))
+	}
+	return rez
+}
+
+// Error of failed pacman, makepkg or repo-add command. Can be matched with
+// errors.As, kind of failure is available with errors.Is.
+type Error struct {
+	// Arguements of failed command.
+	Args []string
+	// Exit code of command, -1 if command was not started or killed.
+	ExitCode int
+	// Error output of command.
+	Stderr string
+	// Error output was written to stderr provided in parameters, so it is
+	// already visible to user.
+	Printed bool
+	// Kind of failure, one of Err* variables, nil if unknown.
+	Kind error
+	// Error returned by runner.
+	Err error
+}
+
+// Create error for failed command from it's arguements, error output and
+// error returned by runner. Nil is returned if err is nil.
+func NewError(args []string, stderr string, err error) *Error {
+	if err == nil {
+		return nil
+	}
+	e := &Error{
+		Args:     args,
+		ExitCode: -1,
+		Stderr:   stderr,
+		Err:      err,
+	}
+	var exiterr interface{ ExitCode() int }
+	if errors.As(err, &exiterr) {
+		e.ExitCode = exiterr.ExitCode()
+	}
+	lines := errorLines(stderr)
+	for _, k := range errorKinds {
+		for _, marker := range k.markers {
+			for _, line := range lines {
+				if marker.MatchString(line) {
+					e.Kind = k.kind
+					return e
+				}
+			}
+		}
+	}
+	return e
+}
+
+// Get lines with 'error: ' and '==> ERROR: ' prefixes from command output,
+// prefixes are trimmed.
+func errorLines(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		for _, prefix := range []string{"error: ", "==> ERROR: "} {
+			if strings.HasPrefix(line, prefix) {
+				lines = append(lines, strings.TrimPrefix(line, prefix))
+			}
+		}
+	}
+	return lines
+}
+
+// Error lines from command output without 'error: ' prefixes, or exit
+// status if there are no such lines.
+func (e *Error) Error() string {
+	lines := errorLines(e.Stderr)
+	if len(lines) > 0 {
+		return strings.Join(lines, "\n")
+	}
+	if out := strings.TrimSpace(e.Stderr); out != `` {
+		return out
+	}
+	return fmt.Sprintf("%s: %v", e.name(), e.Err)
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// Name of executed program, sudo is skipped.
+func (e *Error) name() string {
+	for _, arg := range e.Args {
+		if arg != "sudo" {
+			return path.Base(arg)
+		}
+	}
+	return "command"
+}
+
+// Execute command with runner and convert failure to *Error. Tail of error
+// output is captured in addition to provided stderr.
+func execute(r Runner, cmd *exec.Cmd) error {
+	_, captured := cmd.Stderr.(*bytes.Buffer)
+	printed := cmd.Stderr != nil && !captured
+
+	errb := &tailBuffer{max: 64 * 1024}
+	if cmd.Stderr == nil {
+		cmd.Stderr = errb
+	} else {
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, errb)
+	}
+	err := r.Run(cmd)
+	if err != nil {
+		e := NewError(cmd.Args, string(errb.b), err)
+		e.Printed = printed
+		return e
+	}
+	return nil
+}
+
+// Buffer keeping only last written bytes, so that long build logs do not
+// take up memory.
+type tailBuffer struct {
+	b   []byte
+	max int
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.b = append(t.b, p...)
+	if len(t.b) > t.max {
+		t.b = t.b[len(t.b)-t.max:]
+	}
+	return len(p), nil
+}
@@ -0,0 +1,56 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pacman
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewErrorKind(t *testing.T) {
+	cases := []struct {
+		stderr string
+		kind   error
+	}{
+		{"error: failed to init transaction (unable to lock database)\n", ErrDBLocked},
+		{"error: target not found: nopkg\n", ErrTargetNotFound},
+		{"error: repository \"john.fmnx.su\" was not found.\n", ErrTargetNotFound},
+		{"error: failed to commit transaction (conflicting files)\n", ErrConflict},
+		{":: a and b are in conflict\nerror: unresolvable package conflicts detected\n", ErrConflict},
+		{"error: pack: signature from \"John Doe <john@doe.com>\" is unknown trust\n", ErrSignature},
+		{"error: failed to commit transaction (invalid or corrupted package (PGP signature))\n", ErrSignature},
+		{"error: 'pack-1-1-x86_64.pkg.tar.zst': missing required signature\n", ErrSignature},
+		{"==> ERROR: One or more PGP signatures could not be verified!\n", ErrSignature},
+		{":: unable to satisfy dependency 'a' required by b\nerror: failed to prepare transaction (could not satisfy dependencies)\n", ErrDependency},
+		{"==> ERROR: Could not resolve all dependencies.\n", ErrDependency},
+		// Markers outside of error lines are ignored.
+		{"warning: signature-tools: local (1.0-1) is newer than core (0.9-1)\nerror: failed to prepare transaction\n", nil},
+		{"==> Verifying source file signatures with gpg...\n    pack.tar.gz ... FAILED (unknown public key 34F27D80)\n", nil},
+		{"error: package \"x\" was not found in cache\n", nil},
+	}
+	for _, c := range cases {
+		e := NewError([]string{"pacman"}, c.stderr, FakeExitError(1))
+		if e.Kind != c.kind {
+			t.Errorf("%q: got kind %v, want %v", c.stderr, e.Kind, c.kind)
+		}
+		if c.kind != nil && !errors.Is(e, c.kind) {
+			t.Errorf("%q: error does not match %v", c.stderr, c.kind)
+		}
+	}
+}
+
+func TestListRepoNotFound(t *testing.T) {
+	fake(t, FakeResponse{
+		Prefix: "pacman -Sl",
+		Stderr: "error: repository \"john.fmnx.su\" was not found.\n",
+		Err:    FakeExitError(1),
+	})
+
+	_, err := ListRepo([]string{"john.fmnx.su"})
+	if !errors.Is(err, ErrTargetNotFound) {
+		t.Errorf("expected target not found, got %v", err)
+	}
+}
@@ -6,15 +6,20 @@
 package pacman
 
 import (
+	"context"
+	"os"
 	"os/exec"
 	"sync"
+	"time"
 )
 
 // Dependecy packages.
 const (
-	pacman  = `pacman`
-	makepkg = `makepkg`
-	repoadd = `repo-add`
+	pacman     = `pacman`
+	makepkg    = `makepkg`
+	repoadd    = `repo-add`
+	reporemove = `repo-remove`
+	pacmankey  = `pacman-key`
 )
 
 // Global lock for operations with pacman database.
@@ -27,10 +32,22 @@ func formOptions[Options any](arr []Options, getdefault func() *Options) *Option
 	return &arr[0]
 }
 
-func sudoCommand(sudo bool, command string, args ...string) *exec.Cmd {
+func sudoCommand(ctx context.Context, sudo bool, name string, args ...string) *exec.Cmd {
 	if sudo {
-		args = append([]string{command}, args...)
-		return exec.Command(`sudo`, args...)
+		args = append([]string{name}, args...)
+		return command(ctx, `sudo`, args...)
 	}
-	return exec.Command(command, args...)
+	return command(ctx, name, args...)
+}
+
+// Create command, that is interrupted when context is canceled. Command
+// receives interrupt signal first, so that pacman could release database
+// lock, and is killed if it does not exit in time.
+func command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = 10 * time.Second
+	return cmd
 }
@@ -0,0 +1,96 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pacman
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Parameters for managing pacman keyring.
+type KeyParameters struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	Stdin  io.Reader
+
+	// Additional parameters, that will be appended to command as arguements.
+	AdditionalParams []string
+	// Run with sudo priveleges. [sudo]
+	Sudo bool
+}
+
+func keydefault() *KeyParameters {
+	return &KeyParameters{
+		Sudo:   true,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+		Stdin:  os.Stdin,
+	}
+}
+
+// Add keys from provided files to pacman keyring with pacman-key --add.
+func KeyAdd(files []string, opts ...KeyParameters) error {
+	return KeyAddContext(context.Background(), files, opts...)
+}
+
+// KeyAddContext is like KeyAdd, but command is interrupted when provided
+// context is canceled.
+func KeyAddContext(ctx context.Context, files []string, opts ...KeyParameters) error {
+	err := pacmanKey(ctx, "--add", files, opts)
+	if err != nil {
+		return fmt.Errorf("unable to add keys: %w", err)
+	}
+	return nil
+}
+
+// Locally sign keys with provided fingerprints using pacman-key --lsign-key,
+// so that packages signed with them are trusted by pacman.
+func KeyLocalSign(fingerprints []string, opts ...KeyParameters) error {
+	return KeyLocalSignContext(context.Background(), fingerprints, opts...)
+}
+
+// KeyLocalSignContext is like KeyLocalSign, but command is interrupted when
+// provided context is canceled.
+func KeyLocalSignContext(ctx context.Context, fingerprints []string, opts ...KeyParameters) error {
+	err := pacmanKey(ctx, "--lsign-key", fingerprints, opts)
+	if err != nil {
+		return fmt.Errorf("unable to sign keys: %w", err)
+	}
+	return nil
+}
+
+// Verify detached signature of file with keys from pacman keyring using
+// pacman-key --verify.
+func KeyVerify(sig, file string, opts ...KeyParameters) error {
+	return KeyVerifyContext(context.Background(), sig, file, opts...)
+}
+
+// KeyVerifyContext is like KeyVerify, but command is interrupted when
+// provided context is canceled.
+func KeyVerifyContext(ctx context.Context, sig, file string, opts ...KeyParameters) error {
+	err := pacmanKey(ctx, "--verify", []string{sig, file}, opts)
+	if err != nil {
+		return fmt.Errorf("unable to verify %s: %w", file, err)
+	}
+	return nil
+}
+
+func pacmanKey(ctx context.Context, op string, targets []string, opts []KeyParameters) error {
+	o := formOptions(opts, keydefault)
+
+	args := []string{op}
+	args = append(args, o.AdditionalParams...)
+	args = append(args, targets...)
+
+	cmd := sudoCommand(ctx, o.Sudo, pacmankey, args...)
+	cmd.Stdout = o.Stdout
+	cmd.Stderr = o.Stderr
+	cmd.Stdin = o.Stdin
+
+	return run(cmd)
+}
@@ -6,9 +6,13 @@
 package pacman
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
 	"io"
 	"os"
-	"os/exec"
+	"strings"
 )
 
 // Options for building packages.
@@ -100,6 +104,12 @@ func makepkgdefault() *MakepkgParameters {
 // Function is safe for concurrent usage. Can be called from multiple
 // goruotines, when options Install or SyncDeps are false.
 func Makepkg(opts ...MakepkgParameters) error {
+	return MakepkgContext(context.Background(), opts...)
+}
+
+// MakepkgContext is like Makepkg, but command is interrupted when provided
+// context is canceled.
+func MakepkgContext(ctx context.Context, opts ...MakepkgParameters) error {
 	o := formOptions(opts, makepkgdefault)
 
 	var args []string
@@ -203,11 +213,105 @@ func Makepkg(opts ...MakepkgParameters) error {
 	}
 	args = append(args, o.AdditionalParams...)
 
-	cmd := exec.Command(makepkg, args...)
+	cmd := command(ctx, makepkg, args...)
 	cmd.Dir = o.Dir
 	cmd.Stdin = o.Stdin
 	cmd.Stdout = o.Stdout
 	cmd.Stderr = o.Stderr
 
-	return cmd.Run()
+	return run(cmd)
+}
+
+// Get list of package files, that will be produced by build in provided
+// directory using makepkg --packagelist.
+func PackageList(dir string) ([]string, error) {
+	return PackageListContext(context.Background(), dir)
+}
+
+// PackageListContext is like PackageList, but command is interrupted when
+// provided context is canceled.
+func PackageListContext(ctx context.Context, dir string) ([]string, error) {
+	var b, errb bytes.Buffer
+	cmd := command(ctx, makepkg, "--packagelist")
+	cmd.Dir = dir
+	cmd.Stdout = &b
+	cmd.Stderr = &errb
+
+	err := inspect(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get package list: %w", err)
+	}
+	return strings.Fields(b.String()), nil
+}
+
+// Package information from .SRCINFO, values of all split packages and
+// architectures are merged together.
+type Srcinfo struct {
+	Base         string
+	Names        []string
+	Version      string
+	Depends      []string
+	MakeDepends  []string
+	CheckDepends []string
+	Provides     []string
+}
+
+// Get information about package in provided directory using makepkg
+// --printsrcinfo.
+func PrintSrcinfo(dir string) (*Srcinfo, error) {
+	return PrintSrcinfoContext(context.Background(), dir)
+}
+
+// PrintSrcinfoContext is like PrintSrcinfo, but command is interrupted when
+// provided context is canceled.
+func PrintSrcinfoContext(ctx context.Context, dir string) (*Srcinfo, error) {
+	var b, errb bytes.Buffer
+	cmd := command(ctx, makepkg, "--printsrcinfo")
+	cmd.Dir = dir
+	cmd.Stdout = &b
+	cmd.Stderr = &errb
+
+	err := inspect(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get srcinfo: %w", err)
+	}
+	return parseSrcinfo(&b)
+}
+
+func parseSrcinfo(r io.Reader) (*Srcinfo, error) {
+	var s Srcinfo
+	var pkgver, pkgrel, epoch string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(sc.Text()), " = ")
+		if !ok {
+			continue
+		}
+		key, _, _ = strings.Cut(key, "_")
+		switch key {
+		case "pkgbase":
+			s.Base = value
+		case "pkgname":
+			s.Names = append(s.Names, value)
+		case "pkgver":
+			pkgver = value
+		case "pkgrel":
+			pkgrel = value
+		case "epoch":
+			epoch = value
+		case "depends":
+			s.Depends = append(s.Depends, value)
+		case "makedepends":
+			s.MakeDepends = append(s.MakeDepends, value)
+		case "checkdepends":
+			s.CheckDepends = append(s.CheckDepends, value)
+		case "provides":
+			s.Provides = append(s.Provides, value)
+		}
+	}
+	s.Version = pkgver + "-" + pkgrel
+	if epoch != `` {
+		s.Version = epoch + ":" + s.Version
+	}
+	return &s, sc.Err()
 }
@@ -0,0 +1,32 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pacman
+
+import "testing"
+
+func TestMakepkg(t *testing.T) {
+	f := fake(t)
+
+	err := Makepkg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Makepkg(MakepkgParameters{
+		Dir:       t.TempDir(),
+		SyncDeps:  true,
+		NoConfirm: true,
+		Sign:      true,
+		GpgKey:    "843993E2",
+		File:      "PKGBUILD.git",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCommands(t, f,
+		"makepkg --clean --cleanbuild --force --sign --install",
+		"makepkg --sign --noconfirm -p PKGBUILD.git --key 843993E2 --syncdeps",
+	)
+}
@@ -0,0 +1,211 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pkgfile
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Package archive contents.
+type Package struct {
+	Info  Info
+	Files []string
+}
+
+// Package metadata from .PKGINFO file.
+type Info struct {
+	Name         string
+	Base         string
+	Version      string
+	Desc         string
+	URL          string
+	Arch         string
+	Packager     string
+	BuildDate    time.Time
+	Size         int64
+	Licenses     []string
+	Groups       []string
+	Depends      []string
+	OptDepends   []string
+	MakeDepends  []string
+	CheckDepends []string
+	Provides     []string
+	Conflicts    []string
+	Replaces     []string
+	Backup       []string
+}
+
+// Metadata file located in the root of every package archive.
+const pkginfo = ".PKGINFO"
+
+// Magic number of zstd frame, the only supported package compression.
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// Open package file, read metadata and list of files.
+func Open(file string) (*Package, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Open package file and read only metadata, which is faster than Open, since
+// .PKGINFO is stored at the beginning of archive.
+func OpenInfo(file string) (*Info, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadInfo(f)
+}
+
+// Read package archive from zstd compressed tarball.
+func Read(r io.Reader) (*Package, error) {
+	return read(r, false)
+}
+
+// Read only metadata from zstd compressed tarball.
+func ReadInfo(r io.Reader) (*Info, error) {
+	p, err := read(r, true)
+	if err != nil {
+		return nil, err
+	}
+	return &p.Info, nil
+}
+
+func read(r io.Reader, infoonly bool) (*Package, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(zstdMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if !bytes.Equal(magic, zstdMagic) {
+		return nil, errors.New("package is not compressed with zstd")
+	}
+	zr, err := zstd.NewReader(br)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var p Package
+	var found bool
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Name == pkginfo {
+			info, err := ParseInfo(tr)
+			if err != nil {
+				return nil, err
+			}
+			p.Info = *info
+			found = true
+			if infoonly {
+				return &p, nil
+			}
+			continue
+		}
+		if strings.HasPrefix(hdr.Name, ".") {
+			continue
+		}
+		p.Files = append(p.Files, hdr.Name)
+	}
+	if !found {
+		return nil, errors.New("package does not contain " + pkginfo)
+	}
+	return &p, nil
+}
+
+// Parse .PKGINFO file contents.
+func ParseInfo(r io.Reader) (*Info, error) {
+	var i Info
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, " = ")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "pkgname":
+			i.Name = value
+		case "pkgbase":
+			i.Base = value
+		case "pkgver":
+			i.Version = value
+		case "pkgdesc":
+			i.Desc = value
+		case "url":
+			i.URL = value
+		case "arch":
+			i.Arch = value
+		case "packager":
+			i.Packager = value
+		case "builddate":
+			sec, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, errors.New("not valid builddate: " + value)
+			}
+			i.BuildDate = time.Unix(sec, 0)
+		case "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, errors.New("not valid size: " + value)
+			}
+			i.Size = size
+		case "license":
+			i.Licenses = append(i.Licenses, value)
+		case "group":
+			i.Groups = append(i.Groups, value)
+		case "depend":
+			i.Depends = append(i.Depends, value)
+		case "optdepend":
+			i.OptDepends = append(i.OptDepends, value)
+		case "makedepend":
+			i.MakeDepends = append(i.MakeDepends, value)
+		case "checkdepend":
+			i.CheckDepends = append(i.CheckDepends, value)
+		case "provides":
+			i.Provides = append(i.Provides, value)
+		case "conflict":
+			i.Conflicts = append(i.Conflicts, value)
+		case "replaces":
+			i.Replaces = append(i.Replaces, value)
+		case "backup":
+			i.Backup = append(i.Backup, value)
+		}
+	}
+	err := sc.Err()
+	if err != nil {
+		return nil, err
+	}
+	if i.Name == `` || i.Version == `` || i.Arch == `` {
+		return nil, errors.New("pkgname, pkgver and arch should be defined in " + pkginfo)
+	}
+	return &i, nil
+}
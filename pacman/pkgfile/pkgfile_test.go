@@ -0,0 +1,208 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pkgfile
+
+import (
+	"archive/tar"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const fullInfo = `# Generated by makepkg 6.0.2
+# using fakeroot version 1.32.1
+pkgname = pack
+pkgbase = pack
+pkgver = 1:0.6.2-1
+pkgdesc = Decentralized package manager = pacman + git
+url = https://fmnx.su/core/pack
+builddate = 1700000000
+packager = John Doe <john@doe.com>
+size = 10485760
+arch = x86_64
+license = GPL
+group = fmnx
+depend = pacman
+depend = git
+optdepend = gnupg: signing packages
+makedepend = go
+checkdepend = bash
+provides = pack-bin
+conflict = pack-git
+replaces = pack-old
+backup = etc/pack.conf
+`
+
+func TestParseInfo(t *testing.T) {
+	cases := []struct {
+		name string
+		info string
+		want *Info
+		err  string
+	}{
+		{
+			name: "full",
+			info: fullInfo,
+			want: &Info{
+				Name:         "pack",
+				Base:         "pack",
+				Version:      "1:0.6.2-1",
+				Desc:         "Decentralized package manager = pacman + git",
+				URL:          "https://fmnx.su/core/pack",
+				Arch:         "x86_64",
+				Packager:     "John Doe <john@doe.com>",
+				BuildDate:    time.Unix(1700000000, 0),
+				Size:         10485760,
+				Licenses:     []string{"GPL"},
+				Groups:       []string{"fmnx"},
+				Depends:      []string{"pacman", "git"},
+				OptDepends:   []string{"gnupg: signing packages"},
+				MakeDepends:  []string{"go"},
+				CheckDepends: []string{"bash"},
+				Provides:     []string{"pack-bin"},
+				Conflicts:    []string{"pack-git"},
+				Replaces:     []string{"pack-old"},
+				Backup:       []string{"etc/pack.conf"},
+			},
+		},
+		{
+			name: "minimal with unknown keys",
+			info: "pkgname = a\npkgver = 1-1\narch = any\nxdata = pkgtype=pkg\nnot a field\n",
+			want: &Info{Name: "a", Version: "1-1", Arch: "any"},
+		},
+		{
+			name: "missing arch",
+			info: "pkgname = a\npkgver = 1-1\n",
+			err:  "pkgname, pkgver and arch should be defined",
+		},
+		{
+			name: "bad builddate",
+			info: "pkgname = a\npkgver = 1-1\narch = any\nbuilddate = yesterday\n",
+			err:  "not valid builddate",
+		},
+		{
+			name: "bad size",
+			info: "pkgname = a\npkgver = 1-1\narch = any\nsize = big\n",
+			err:  "not valid size",
+		},
+	}
+	for _, c := range cases {
+		got, err := ParseInfo(strings.NewReader(c.info))
+		switch {
+		case c.err != ``:
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s: expected error %q, got %v", c.name, c.err, err)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", c.name, err)
+		case !reflect.DeepEqual(got, c.want):
+			t.Errorf("%s:\n  got:  %+v\n  want: %+v", c.name, got, c.want)
+		}
+	}
+}
+
+// Create zstd compressed tarball with provided files, files are written in
+// provided order.
+func archive(t *testing.T, files ...[2]string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	zw, err := zstd.NewWriter(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(zw)
+	for _, f := range files {
+		err = tw.WriteHeader(&tar.Header{Name: f[0], Mode: 0644, Size: int64(len(f[1]))})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = tw.Write([]byte(f[1]))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = tw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = zw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	pkg := archive(t,
+		[2]string{".BUILDINFO", "format = 2\n"},
+		[2]string{".PKGINFO", fullInfo},
+		[2]string{".MTREE", ""},
+		[2]string{"usr/bin/pack", "binary"},
+		[2]string{"etc/pack.conf", "[defaults]\n"},
+	)
+
+	p, err := Read(bytes.NewReader(pkg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Info.Name != "pack" || p.Info.Version != "1:0.6.2-1" || p.Info.Arch != "x86_64" {
+		t.Errorf("unexpected info: %+v", p.Info)
+	}
+	if want := []string{"usr/bin/pack", "etc/pack.conf"}; !reflect.DeepEqual(p.Files, want) {
+		t.Errorf("files: got %q, want %q", p.Files, want)
+	}
+
+	info, err := ReadInfo(bytes.NewReader(pkg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*info, p.Info) {
+		t.Errorf("ReadInfo differs from Read:\n  %+v\n  %+v", *info, p.Info)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{
+			name: "no pkginfo",
+			data: archive(t, [2]string{"usr/bin/pack", "binary"}),
+			err:  "package does not contain .PKGINFO",
+		},
+		{
+			name: "xz",
+			data: []byte{0xfd, '7', 'z', 'X', 'Z', 0x00, 0x00, 0x04},
+			err:  "package is not compressed with zstd",
+		},
+		{
+			name: "gzip",
+			data: []byte{0x1f, 0x8b, 0x08, 0x00},
+			err:  "package is not compressed with zstd",
+		},
+		{
+			name: "empty",
+			data: nil,
+			err:  "package is not compressed with zstd",
+		},
+		{
+			name: "truncated",
+			data: archive(t, [2]string{"usr/bin/pack", "binary"})[:12],
+		},
+	}
+	for _, c := range cases {
+		_, err := Read(bytes.NewReader(c.data))
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: expected error %q, got %v", c.name, c.err, err)
+		}
+	}
+}
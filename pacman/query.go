@@ -7,9 +7,9 @@ package pacman
 
 import (
 	"bytes"
-	"errors"
+	"context"
+	"fmt"
 	"io"
-	"os/exec"
 	"strings"
 )
 
@@ -50,12 +50,18 @@ func QueryDefault() *QueryParameters {
 }
 
 type PackageInfo struct {
-	Name    string
-	Version string
+	Name    string `json:"name"`
+	Version string `json:"version"`
 }
 
 // Get information about installed packages.
 func Query(pkgs []string, opts ...QueryParameters) error {
+	return QueryContext(context.Background(), pkgs, opts...)
+}
+
+// QueryContext is like Query, but command is interrupted when provided
+// context is canceled.
+func QueryContext(ctx context.Context, pkgs []string, opts ...QueryParameters) error {
 	o := formOptions(opts, QueryDefault)
 
 	args := []string{"-Q"}
@@ -97,48 +103,83 @@ func Query(pkgs []string, opts ...QueryParameters) error {
 	args = append(args, o.AdditionalParams...)
 	args = append(args, pkgs...)
 
-	cmd := exec.Command(pacman, args...)
+	cmd := command(ctx, pacman, args...)
 	cmd.Stdout = o.Stdout
 	cmd.Stderr = o.Stderr
 	cmd.Stdin = o.Stdin
 
-	return cmd.Run()
+	return inspect(cmd)
+}
+
+// Get names and versions of installed packages, all packages are listed if
+// no names provided.
+func QueryList(pkgs []string) ([]PackageInfo, error) {
+	return QueryListContext(context.Background(), pkgs)
+}
+
+// QueryListContext is like QueryList, but command is interrupted when provided
+// context is canceled.
+func QueryListContext(ctx context.Context, pkgs []string) ([]PackageInfo, error) {
+	var b, errb bytes.Buffer
+	cmd := command(ctx, pacman, append([]string{"-Q"}, pkgs...)...)
+	cmd.Stdout = &b
+	cmd.Stderr = &errb
+
+	err := inspect(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query packages: %w", err)
+	}
+	var rez []PackageInfo
+	for _, line := range strings.Split(b.String(), "\n") {
+		name, version, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		rez = append(rez, PackageInfo{Name: name, Version: version})
+	}
+	return rez, nil
 }
 
 type PackageInfoFull struct {
-	Name          string
-	Version       string
-	Description   string
-	Architecture  string
-	URL           string
-	Licenses      string
-	Groups        string
-	Provides      string
-	DependsOn     string
-	OptionalDeps  string
-	RequiredBy    string
-	OptionalFor   string
-	ConflictsWith string
-	Replaces      string
-	InstalledSize string
-	Packager      string
-	BuildDate     string
-	InstallDate   string
-	InstallReason string
-	InstallScript string
-	ValidatedBy   string
+	Name          string `json:"name"`
+	Version       string `json:"version"`
+	Description   string `json:"description"`
+	Architecture  string `json:"architecture"`
+	URL           string `json:"url"`
+	Licenses      string `json:"licenses"`
+	Groups        string `json:"groups"`
+	Provides      string `json:"provides"`
+	DependsOn     string `json:"depends_on"`
+	OptionalDeps  string `json:"optional_deps"`
+	RequiredBy    string `json:"required_by"`
+	OptionalFor   string `json:"optional_for"`
+	ConflictsWith string `json:"conflicts_with"`
+	Replaces      string `json:"replaces"`
+	InstalledSize string `json:"installed_size"`
+	Packager      string `json:"packager"`
+	BuildDate     string `json:"build_date"`
+	InstallDate   string `json:"install_date"`
+	InstallReason string `json:"install_reason"`
+	InstallScript string `json:"install_script"`
+	ValidatedBy   string `json:"validated_by"`
 }
 
 // Get info about package.
 func Info(pkg string) (*PackageInfoFull, error) {
+	return InfoContext(context.Background(), pkg)
+}
+
+// InfoContext is like Info, but command is interrupted when provided
+// context is canceled.
+func InfoContext(ctx context.Context, pkg string) (*PackageInfoFull, error) {
 	var b bytes.Buffer
-	cmd := exec.Command(pacman, "-Qi", pkg)
+	cmd := command(ctx, pacman, "-Qi", pkg)
 	cmd.Stdout = &b
 	cmd.Stderr = &b
 
-	err := cmd.Run()
+	err := inspect(cmd)
 	if err != nil {
-		return nil, errors.New("unable to get info: " + b.String())
+		return nil, fmt.Errorf("unable to get info: %w", err)
 	}
 	out := b.String()
 
@@ -174,24 +215,30 @@ func parseField(full string, field string) string {
 
 // Outdated package.
 type OutdatedPackage struct {
-	Name           string
-	CurrentVersion string
-	NewVersion     string
+	Name           string `json:"name"`
+	CurrentVersion string `json:"current_version"`
+	NewVersion     string `json:"new_version"`
 }
 
 // Get information about outdated packages.
 func Outdated() ([]OutdatedPackage, error) {
+	return OutdatedContext(context.Background())
+}
+
+// OutdatedContext is like Outdated, but command is interrupted when provided
+// context is canceled.
+func OutdatedContext(ctx context.Context) ([]OutdatedPackage, error) {
 	var b bytes.Buffer
-	cmd := exec.Command(pacman, "-Qu")
+	cmd := command(ctx, pacman, "-Qu")
 	cmd.Stdout = &b
 	cmd.Stderr = &b
 
-	err := cmd.Run()
+	err := inspect(cmd)
 	if err != nil {
 		if b.String() == `` {
 			return nil, nil
 		}
-		return nil, errors.New("unable to get info: " + b.String())
+		return nil, fmt.Errorf("unable to get info: %w", err)
 	}
 	out := b.String()
 	return parseOutdated(out), nil
@@ -215,13 +262,19 @@ func parseOutdated(o string) []OutdatedPackage {
 
 // Get raw file infor for provided package using `pacman -Qp`.
 func RawFileInfo(filepath string) (string, error) {
+	return RawFileInfoContext(context.Background(), filepath)
+}
+
+// RawFileInfoContext is like RawFileInfo, but command is interrupted when
+// provided context is canceled.
+func RawFileInfoContext(ctx context.Context, filepath string) (string, error) {
 	var b bytes.Buffer
-	cmd := exec.Command("pacman", "-Qpi", filepath)
+	cmd := command(ctx, "pacman", "-Qpi", filepath)
 	cmd.Stdout = &b
 	cmd.Stderr = &b
-	err := cmd.Run()
+	err := inspect(cmd)
 	if err != nil {
-		return ``, errors.New(b.String())
+		return ``, err
 	}
 	return b.String(), nil
 }
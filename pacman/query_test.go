@@ -0,0 +1,63 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pacman
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	f := fake(t)
+
+	err := Query([]string{"pack"}, QueryParameters{
+		Explicit: true,
+		Foreign:  true,
+		Info:     []bool{true, true},
+		File:     "pack.pkg.tar.zst",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Query(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCommands(t, f,
+		"pacman -Q --explicit --foreign -i -i --file pack.pkg.tar.zst pack",
+		"pacman -Q",
+	)
+}
+
+func TestOutdated(t *testing.T) {
+	f := fake(t, FakeResponse{
+		Prefix: "pacman -Qu",
+		Stdout: "linux 6.4.12.arch1-1 -> 6.5.arch1-1\n" +
+			"pack 0.8-1 -> 0.9-1\n",
+	})
+
+	rez, err := Outdated()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []OutdatedPackage{
+		{Name: "linux", CurrentVersion: "6.4.12.arch1-1", NewVersion: "6.5.arch1-1"},
+		{Name: "pack", CurrentVersion: "0.8-1", NewVersion: "0.9-1"},
+	}
+	if !reflect.DeepEqual(rez, want) {
+		t.Errorf("outdated:\n  got:  %+v\n  want: %+v", rez, want)
+	}
+	checkCommands(t, f, "pacman -Qu")
+}
+
+func TestOutdatedUpToDate(t *testing.T) {
+	fake(t, FakeResponse{Prefix: "pacman -Qu", Err: FakeExitError(1)})
+
+	rez, err := Outdated()
+	if err != nil || rez != nil {
+		t.Errorf("expected no outdated packages, got %v %v", rez, err)
+	}
+}
@@ -6,6 +6,7 @@
 package pacman
 
 import (
+	"context"
 	"io"
 	"os"
 	"strings"
@@ -48,8 +49,20 @@ func Remove(pkgs string, opts ...RemoveParameters) error {
 	return RemoveList(strings.Split(pkgs, " "), opts...)
 }
 
+// RemoveContext is like Remove, but command is interrupted when provided
+// context is canceled.
+func RemoveContext(ctx context.Context, pkgs string, opts ...RemoveParameters) error {
+	return RemoveListContext(ctx, strings.Split(pkgs, " "), opts...)
+}
+
 // Remove packages from system.
 func RemoveList(pkgs []string, opts ...RemoveParameters) error {
+	return RemoveListContext(context.Background(), pkgs, opts...)
+}
+
+// RemoveListContext is like RemoveList, but command is interrupted when
+// provided context is canceled.
+func RemoveListContext(ctx context.Context, pkgs []string, opts ...RemoveParameters) error {
 	o := formOptions(opts, RemoveDefault)
 
 	var args = []string{"-R"}
@@ -71,12 +84,12 @@ func RemoveList(pkgs []string, opts ...RemoveParameters) error {
 	args = append(args, o.AdditionalParams...)
 	args = append(args, pkgs...)
 
-	cmd := sudoCommand(o.Sudo, pacman, args...)
+	cmd := sudoCommand(ctx, o.Sudo, pacman, args...)
 	cmd.Stdout = o.Stdout
 	cmd.Stderr = o.Stderr
 	cmd.Stdin = o.Stdin
 
 	mu.Lock()
 	defer mu.Unlock()
-	return cmd.Run()
+	return run(cmd)
 }
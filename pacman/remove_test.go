@@ -0,0 +1,30 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pacman
+
+import "testing"
+
+func TestRemoveList(t *testing.T) {
+	f := fake(t)
+
+	err := RemoveList([]string{"pack", "git"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = RemoveList([]string{"pack"}, RemoveParameters{
+		Sudo:           true,
+		NoConfirm:      true,
+		ForceRecursive: true,
+		Cascade:        true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCommands(t, f,
+		"pacman -R --recursive --nosave pack git",
+		"sudo pacman -R --noconfirm -ss --cascade pack",
+	)
+}
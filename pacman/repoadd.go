@@ -6,6 +6,7 @@
 package pacman
 
 import (
+	"context"
 	"io"
 	"os"
 	"sync"
@@ -54,6 +55,12 @@ var dbmu sync.Mutex
 // This function will add new packages to database. You should provide valid
 // path for database file and path to package you want to add.
 func RepoAdd(dbfile, pkgfile string, opts ...RepoAddParameters) error {
+	return RepoAddContext(context.Background(), dbfile, pkgfile, opts...)
+}
+
+// RepoAddContext is like RepoAdd, but command is interrupted when provided
+// context is canceled.
+func RepoAddContext(ctx context.Context, dbfile, pkgfile string, opts ...RepoAddParameters) error {
 	// Later rewrite this to mutex for only specific checked dbfile.
 	dbmu.Lock()
 	defer dbmu.Unlock()
@@ -87,11 +94,11 @@ func RepoAdd(dbfile, pkgfile string, opts ...RepoAddParameters) error {
 	args = append(args, dbfile)
 	args = append(args, pkgfile)
 
-	cmd := sudoCommand(o.Sudo, repoadd, args...)
+	cmd := sudoCommand(ctx, o.Sudo, repoadd, args...)
 	cmd.Dir = o.Dir
 	cmd.Stderr = o.Stderr
 	cmd.Stdout = o.Stdout
 	cmd.Stdin = o.Stdin
 
-	return cmd.Run()
+	return run(cmd)
 }
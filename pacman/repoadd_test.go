@@ -0,0 +1,30 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pacman
+
+import "testing"
+
+func TestRepoAdd(t *testing.T) {
+	f := fake(t)
+
+	err := RepoAdd("repo.db.tar.gz", "pack.pkg.tar.zst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = RepoAdd("repo.db.tar.gz", "pack.pkg.tar.zst", RepoAddParameters{
+		Sudo:   true,
+		Remove: true,
+		Sign:   true,
+		Key:    "843993E2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCommands(t, f,
+		"repo-add --new --prevent-downgrade repo.db.tar.gz pack.pkg.tar.zst",
+		"sudo repo-add --remove --sign --key 843993E2 repo.db.tar.gz pack.pkg.tar.zst",
+	)
+}
@@ -0,0 +1,83 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pacman
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// Parameters for removing packages from pacman repo.
+type RepoRemoveParameters struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	Stdin  io.Reader
+
+	// Additional parameters, that will be appended to command as arguements.
+	AdditionalParams []string
+	// Directory where process will be executed.
+	Dir string
+	// Use the specified key to sign the database. [--key <file>]
+	Key string
+	// Run with sudo priveleges. [sudo]
+	Sudo bool
+	// Turn off color in output. [--nocolor]
+	NoColor bool
+	// Sign database with GnuPG after update. [--sign]
+	Sign bool
+	// Verify database signature before update. [--verify]
+	Verify bool
+}
+
+func RepoRemoveDefaultOptions() *RepoRemoveParameters {
+	return &RepoRemoveParameters{
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+		Stdin:  os.Stdin,
+	}
+}
+
+// This function will remove packages from database. You should provide valid
+// path for database file and names of packages you want to remove.
+func RepoRemove(dbfile string, pkgs []string, opts ...RepoRemoveParameters) error {
+	return RepoRemoveContext(context.Background(), dbfile, pkgs, opts...)
+}
+
+// RepoRemoveContext is like RepoRemove, but command is interrupted when
+// provided context is canceled.
+func RepoRemoveContext(ctx context.Context, dbfile string, pkgs []string, opts ...RepoRemoveParameters) error {
+	dbmu.Lock()
+	defer dbmu.Unlock()
+
+	o := formOptions(opts, RepoRemoveDefaultOptions)
+
+	var args []string
+	if o.NoColor {
+		args = append(args, "--nocolor")
+	}
+	if o.Sign {
+		args = append(args, "--sign")
+	}
+	if o.Verify {
+		args = append(args, "--verify")
+	}
+	if o.Key != "" {
+		args = append(args, "--key")
+		args = append(args, o.Key)
+	}
+	args = append(args, o.AdditionalParams...)
+	args = append(args, dbfile)
+	args = append(args, pkgs...)
+
+	cmd := sudoCommand(ctx, o.Sudo, reporemove, args...)
+	cmd.Dir = o.Dir
+	cmd.Stderr = o.Stderr
+	cmd.Stdout = o.Stdout
+	cmd.Stdin = o.Stdin
+
+	return run(cmd)
+}
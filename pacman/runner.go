@@ -0,0 +1,140 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pacman
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Runner executes commands prepared by wrappers of this package. Command
+// has arguements, directory and standard streams already set.
+type Runner interface {
+	Run(cmd *exec.Cmd) error
+}
+
+// Runner executing commands with os/exec.
+type ExecRunner struct{}
+
+func (ExecRunner) Run(cmd *exec.Cmd) error {
+	return cmd.Run()
+}
+
+// Runner used by all wrappers in this package, can be replaced with
+// FakeRunner to test code using this package without pacman installed.
+var DefaultRunner Runner = ExecRunner{}
+
+// Runner for commands modifying system, like package installation, removal,
+// build or database updates. Can be set to print or record commands in dry
+// run mode, read only commands are still executed with DefaultRunner. Dry
+// run is disabled if runner is nil.
+var DryRun Runner
+
+// Run command modifying system, in dry run mode command is passed to DryRun
+// runner. Failures are returned as *Error.
+func run(cmd *exec.Cmd) error {
+	if DryRun != nil {
+		return execute(DryRun, cmd)
+	}
+	return execute(DefaultRunner, cmd)
+}
+
+// Run read only command, which is executed even in dry run mode.
+func inspect(cmd *exec.Cmd) error {
+	return execute(DefaultRunner, cmd)
+}
+
+// Canned result of command executed with FakeRunner.
+type FakeResponse struct {
+	// Command line prefix (arguements joined with spaces, including sudo),
+	// response is used for first command starting with it.
+	Prefix string
+	// Working directory of command, response matches commands executed in
+	// any directory if empty.
+	Dir string
+	// Output written to command's stdout and stderr.
+	Stdout string
+	Stderr string
+	// Error returned from Run, for example FakeExitError.
+	Err error
+}
+
+// Runner, that records arguements of executed commands and replays canned
+// output instead of running them. Commands without matching response
+// succeed with empty output.
+type FakeRunner struct {
+	mu        sync.Mutex
+	calls     [][]string
+	responses []FakeResponse
+}
+
+// Create fake runner with provided responses.
+func NewFakeRunner(responses ...FakeResponse) *FakeRunner {
+	return &FakeRunner{responses: responses}
+}
+
+func (f *FakeRunner) Run(cmd *exec.Cmd) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, append([]string{}, cmd.Args...))
+
+	line := strings.Join(cmd.Args, " ")
+	for _, r := range f.responses {
+		if !strings.HasPrefix(line, r.Prefix) || (r.Dir != `` && r.Dir != cmd.Dir) {
+			continue
+		}
+		err := errors.Join(
+			write(cmd.Stdout, r.Stdout),
+			write(cmd.Stderr, r.Stderr),
+		)
+		if err != nil {
+			return err
+		}
+		return r.Err
+	}
+	return nil
+}
+
+// Arguements of all commands executed with runner.
+func (f *FakeRunner) Calls() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]string{}, f.calls...)
+}
+
+// Command lines of all commands executed with runner, arguements are joined
+// with spaces.
+func (f *FakeRunner) Commands() []string {
+	var rez []string
+	for _, args := range f.Calls() {
+		rez = append(rez, strings.Join(args, " "))
+	}
+	return rez
+}
+
+// Error returned by FakeRunner to simulate command exiting with non zero
+// code.
+type FakeExitError int
+
+func (e FakeExitError) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+func (e FakeExitError) ExitCode() int {
+	return int(e)
+}
+
+func write(w io.Writer, s string) error {
+	if w == nil || s == `` {
+		return nil
+	}
+	_, err := io.WriteString(w, s)
+	return err
+}
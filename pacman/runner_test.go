@@ -0,0 +1,52 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pacman
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Replace DefaultRunner with fake runner for duration of the test.
+func fake(t *testing.T, responses ...FakeResponse) *FakeRunner {
+	t.Helper()
+	f := NewFakeRunner(responses...)
+	prev := DefaultRunner
+	DefaultRunner = f
+	t.Cleanup(func() { DefaultRunner = prev })
+	return f
+}
+
+// Check that runner executed exactly provided command lines.
+func checkCommands(t *testing.T, f *FakeRunner, want ...string) {
+	t.Helper()
+	got := f.Commands()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("commands:\n  got:  %q\n  want: %q", got, want)
+	}
+}
+
+func TestFakeRunnerPrefix(t *testing.T) {
+	f := fake(t,
+		FakeResponse{Prefix: "pacman -Q", Stdout: "pack 1.0-1\n"},
+		FakeResponse{Prefix: "pacman", Stderr: "error: failed\n", Err: FakeExitError(1)},
+	)
+
+	pkgs, err := QueryList(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 || pkgs[0] != (PackageInfo{Name: "pack", Version: "1.0-1"}) {
+		t.Errorf("unexpected packages: %+v", pkgs)
+	}
+
+	_, err = ListRepo(nil)
+	if err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("expected replayed error, got %v", err)
+	}
+	checkCommands(t, f, "pacman -Q", "pacman -Sl")
+}
@@ -7,7 +7,8 @@ package pacman
 
 import (
 	"bytes"
-	"errors"
+	"context"
+	"fmt"
 	"io"
 	"os"
 	"strings"
@@ -69,8 +70,20 @@ func Sync(pkgs string, opts ...SyncParameters) error {
 	return SyncList(strings.Split(pkgs, " "), opts...)
 }
 
+// SyncContext is like Sync, but command is interrupted when provided
+// context is canceled.
+func SyncContext(ctx context.Context, pkgs string, opts ...SyncParameters) error {
+	return SyncListContext(ctx, strings.Split(pkgs, " "), opts...)
+}
+
 // Sync command for package string list.
 func SyncList(pkgs []string, opts ...SyncParameters) error {
+	return SyncListContext(context.Background(), pkgs, opts...)
+}
+
+// SyncListContext is like SyncList, but command is interrupted when provided
+// context is canceled.
+func SyncListContext(ctx context.Context, pkgs []string, opts ...SyncParameters) error {
 	o := formOptions(opts, SyncDefault)
 
 	args := []string{"-S"}
@@ -119,14 +132,14 @@ func SyncList(pkgs []string, opts ...SyncParameters) error {
 	args = append(args, o.AdditionalParams...)
 	args = append(args, pkgs...)
 
-	cmd := sudoCommand(o.Sudo, pacman, args...)
+	cmd := sudoCommand(ctx, o.Sudo, pacman, args...)
 	cmd.Stdout = o.Stdout
 	cmd.Stderr = o.Stderr
 	cmd.Stdin = o.Stdin
 
 	mu.Lock()
 	defer mu.Unlock()
-	return cmd.Run()
+	return run(cmd)
 }
 
 // Options to apply when searching for some package.
@@ -141,10 +154,10 @@ type SearchOptions struct {
 
 // Structure to recieve from search result
 type SearchResult struct {
-	Repo    string
-	Name    string
-	Version string
-	Desc    string
+	Repo    string `json:"repo"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Desc    string `json:"desc"`
 }
 
 func SearchDefault() *SearchOptions {
@@ -156,6 +169,12 @@ func SearchDefault() *SearchOptions {
 
 // Search for packages.
 func Search(re string, opts ...SearchOptions) ([]SearchResult, error) {
+	return SearchContext(context.Background(), re, opts...)
+}
+
+// SearchContext is like Search, but command is interrupted when provided
+// context is canceled.
+func SearchContext(ctx context.Context, re string, opts ...SearchOptions) ([]SearchResult, error) {
 	o := formOptions(opts, SearchDefault)
 
 	args := []string{"-Ss"}
@@ -165,24 +184,72 @@ func Search(re string, opts ...SearchOptions) ([]SearchResult, error) {
 	args = append(args, re)
 
 	var b bytes.Buffer
-	cmd := sudoCommand(o.Sudo, pacman, args...)
+	cmd := sudoCommand(ctx, o.Sudo, pacman, args...)
 	cmd.Stdout = &b
 	cmd.Stderr = &b
 	cmd.Stdin = os.Stdin
 
 	mu.Lock()
 	defer mu.Unlock()
-	err := cmd.Run()
+	// Refreshing databases modifies system.
+	var err error
+	if o.Refresh {
+		err = run(cmd)
+	} else {
+		err = inspect(cmd)
+	}
 
 	if err != nil {
 		if b.String() == `` {
 			return nil, nil
 		}
-		return nil, errors.New("unable to search: " + b.String())
+		return nil, fmt.Errorf("unable to search: %w", err)
 	}
 	return serializeOutput(b.String()), nil
 }
 
+// Package from sync database.
+type RepoPackage struct {
+	Repo      string `json:"repo"`
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	Installed bool   `json:"installed"`
+}
+
+// List packages in provided sync databases with pacman -Sl, packages from
+// all databases are listed if no names provided.
+func ListRepo(repos []string) ([]RepoPackage, error) {
+	return ListRepoContext(context.Background(), repos)
+}
+
+// ListRepoContext is like ListRepo, but command is interrupted when provided
+// context is canceled.
+func ListRepoContext(ctx context.Context, repos []string) ([]RepoPackage, error) {
+	var b, errb bytes.Buffer
+	cmd := command(ctx, pacman, append([]string{"-Sl"}, repos...)...)
+	cmd.Stdout = &b
+	cmd.Stderr = &errb
+
+	err := inspect(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list repositories: %w", err)
+	}
+	var rez []RepoPackage
+	for _, line := range strings.Split(b.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		rez = append(rez, RepoPackage{
+			Repo:      fields[0],
+			Name:      fields[1],
+			Version:   fields[2],
+			Installed: len(fields) > 3 && strings.HasPrefix(fields[3], "[installed"),
+		})
+	}
+	return rez, nil
+}
+
 func serializeOutput(output string) []SearchResult {
 	if strings.HasPrefix(output, ":: Synchronizing package databases") {
 		splt := strings.Split(output, "downloading...\n")
@@ -0,0 +1,117 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pacman
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSyncList(t *testing.T) {
+	f := fake(t)
+
+	err := SyncList([]string{"pack", "git"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = SyncList([]string{"pack"}, SyncParameters{
+		AsDeps:           true,
+		NoProgressBar:    true,
+		Refresh:          []bool{true, true},
+		Upgrade:          []bool{true},
+		AdditionalParams: []string{"--overwrite", "*"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCommands(t, f,
+		"sudo pacman -S --needed --noconfirm -y pack git",
+		"pacman -S --noprogressbar --asdeps -y -y -u --overwrite * pack",
+	)
+}
+
+func TestSyncListError(t *testing.T) {
+	fake(t, FakeResponse{
+		Prefix: "sudo pacman -S",
+		Stderr: "error: target not found: nopkg\n",
+		Err:    FakeExitError(1),
+	})
+
+	err := SyncList([]string{"nopkg"})
+	if !errors.Is(err, ErrTargetNotFound) {
+		t.Fatalf("expected target not found, got %v", err)
+	}
+	var perr *Error
+	if !errors.As(err, &perr) || perr.ExitCode != 1 {
+		t.Fatalf("expected pacman error with exit code 1, got %#v", err)
+	}
+	if perr.Error() != "target not found: nopkg" {
+		t.Errorf("unexpected message: %s", perr.Error())
+	}
+}
+
+func TestSearch(t *testing.T) {
+	f := fake(t, FakeResponse{
+		Prefix: "pacman -Ss",
+		Stdout: ":: Synchronizing package databases...\n" +
+			" core downloading...\n" +
+			"core/linux 6.5.arch1-1 [installed]\n" +
+			"    The Linux kernel and modules\n" +
+			"extra/linux-docs 6.5.arch1-1\n" +
+			"    Documentation for the Linux kernel\n",
+	})
+
+	rez, err := Search("linux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SearchResult{
+		{Repo: "core", Name: "linux", Version: "6.5.arch1-1", Desc: "The Linux kernel and modules"},
+		{Repo: "extra", Name: "linux-docs", Version: "6.5.arch1-1", Desc: "Documentation for the Linux kernel"},
+	}
+	if !reflect.DeepEqual(rez, want) {
+		t.Errorf("search results:\n  got:  %+v\n  want: %+v", rez, want)
+	}
+
+	_, err = Search("linux", SearchOptions{Sudo: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCommands(t, f, "pacman -Ss --refresh linux", "sudo pacman -Ss linux")
+}
+
+func TestSearchNotFound(t *testing.T) {
+	fake(t, FakeResponse{Prefix: "pacman -Ss", Err: FakeExitError(1)})
+
+	rez, err := Search("nopkg", SearchOptions{})
+	if err != nil || rez != nil {
+		t.Errorf("expected empty result without error, got %v %v", rez, err)
+	}
+}
+
+func TestListRepo(t *testing.T) {
+	f := fake(t, FakeResponse{
+		Prefix: "pacman -Sl",
+		Stdout: "john.fmnx.su pack 0.9-1 [installed: 0.8-1]\n" +
+			"john.fmnx.su ainst 1.0-1 [installed]\n" +
+			"john.fmnx.su gitea 1.20-2\n",
+	})
+
+	rez, err := ListRepo([]string{"john.fmnx.su"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []RepoPackage{
+		{Repo: "john.fmnx.su", Name: "pack", Version: "0.9-1", Installed: true},
+		{Repo: "john.fmnx.su", Name: "ainst", Version: "1.0-1", Installed: true},
+		{Repo: "john.fmnx.su", Name: "gitea", Version: "1.20-2"},
+	}
+	if !reflect.DeepEqual(rez, want) {
+		t.Errorf("packages:\n  got:  %+v\n  want: %+v", rez, want)
+	}
+	checkCommands(t, f, "pacman -Sl john.fmnx.su")
+}
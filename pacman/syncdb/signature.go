@@ -0,0 +1,44 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package syncdb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// Get fingerprint (or key ID for older signatures) of key that was used to
+// sign package. Returns empty string if package is not signed.
+func (p Package) Signer() (string, error) {
+	if p.PGPSig == `` {
+		return ``, nil
+	}
+	b, err := base64.StdEncoding.DecodeString(p.PGPSig)
+	if err != nil {
+		return ``, err
+	}
+	pkt, err := packet.Read(bytes.NewReader(b))
+	if err != nil {
+		return ``, fmt.Errorf("unable to read signature: %w", err)
+	}
+	sig, ok := pkt.(*packet.Signature)
+	if !ok {
+		return ``, errors.New("packet is not a signature")
+	}
+	switch {
+	case len(sig.IssuerFingerprint) > 0:
+		return strings.ToUpper(hex.EncodeToString(sig.IssuerFingerprint)), nil
+	case sig.IssuerKeyId != nil:
+		return fmt.Sprintf("%016X", *sig.IssuerKeyId), nil
+	}
+	return ``, nil
+}
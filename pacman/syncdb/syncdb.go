@@ -0,0 +1,164 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package syncdb
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Package entry in pacman sync database.
+type Package struct {
+	Filename      string   `json:"filename"`
+	Name          string   `json:"name"`
+	Base          string   `json:"base"`
+	Version       string   `json:"version"`
+	Desc          string   `json:"desc"`
+	URL           string   `json:"url"`
+	Arch          string   `json:"arch"`
+	Packager      string   `json:"packager"`
+	BuildDate     int64    `json:"build_date"`
+	CompressSize  int64    `json:"compress_size"`
+	InstalledSize int64    `json:"installed_size"`
+	SHA256Sum     string   `json:"sha256sum"`
+	PGPSig        string   `json:"pgpsig"`
+	Licenses      []string `json:"licenses"`
+	Groups        []string `json:"groups"`
+	Depends       []string `json:"depends"`
+	OptDepends    []string `json:"opt_depends"`
+	MakeDepends   []string `json:"make_depends"`
+	Provides      []string `json:"provides"`
+	Conflicts     []string `json:"conflicts"`
+	Replaces      []string `json:"replaces"`
+}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Read packages from pacman sync database (for example 'core.db'). Database
+// can be compressed with gzip, zstd or not compressed at all.
+func Read(r io.Reader) ([]Package, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(4)
+
+	var src io.Reader = br
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		src = gr
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		src = zr
+	}
+
+	var pkgs []Package
+	tr := tar.NewReader(src)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if path.Base(hdr.Name) != "desc" {
+			continue
+		}
+		pkg, err := parseDesc(tr)
+		if err != nil {
+			return nil, errors.Join(errors.New("unable to parse "+hdr.Name), err)
+		}
+		pkgs = append(pkgs, *pkg)
+	}
+	return pkgs, nil
+}
+
+// Parse desc file of database entry, which consists of %FIELD% headers
+// followed by values, one per line, and separated with empty lines.
+func parseDesc(r io.Reader) (*Package, error) {
+	var p Package
+	var field string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == `` {
+			field = ``
+			continue
+		}
+		if strings.HasPrefix(line, "%") && strings.HasSuffix(line, "%") {
+			field = strings.Trim(line, "%")
+			continue
+		}
+		var err error
+		switch field {
+		case "FILENAME":
+			p.Filename = line
+		case "NAME":
+			p.Name = line
+		case "BASE":
+			p.Base = line
+		case "VERSION":
+			p.Version = line
+		case "DESC":
+			p.Desc = line
+		case "URL":
+			p.URL = line
+		case "ARCH":
+			p.Arch = line
+		case "PACKAGER":
+			p.Packager = line
+		case "BUILDDATE":
+			p.BuildDate, err = strconv.ParseInt(line, 10, 64)
+		case "CSIZE":
+			p.CompressSize, err = strconv.ParseInt(line, 10, 64)
+		case "ISIZE":
+			p.InstalledSize, err = strconv.ParseInt(line, 10, 64)
+		case "SHA256SUM":
+			p.SHA256Sum = line
+		case "PGPSIG":
+			p.PGPSig = line
+		case "LICENSE":
+			p.Licenses = append(p.Licenses, line)
+		case "GROUPS":
+			p.Groups = append(p.Groups, line)
+		case "DEPENDS":
+			p.Depends = append(p.Depends, line)
+		case "OPTDEPENDS":
+			p.OptDepends = append(p.OptDepends, line)
+		case "MAKEDEPENDS":
+			p.MakeDepends = append(p.MakeDepends, line)
+		case "PROVIDES":
+			p.Provides = append(p.Provides, line)
+		case "CONFLICTS":
+			p.Conflicts = append(p.Conflicts, line)
+		case "REPLACES":
+			p.Replaces = append(p.Replaces, line)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &p, sc.Err()
+}
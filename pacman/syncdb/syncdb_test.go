@@ -0,0 +1,240 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package syncdb
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/klauspost/compress/zstd"
+)
+
+const packDesc = `%FILENAME%
+pack-0.6.2-1-x86_64.pkg.tar.zst
+
+%NAME%
+pack
+
+%BASE%
+pack
+
+%VERSION%
+0.6.2-1
+
+%DESC%
+Decentralized package manager
+
+%CSIZE%
+2451022
+
+%ISIZE%
+7897088
+
+%SHA256SUM%
+0c2bb5a3e3e8cd4e2c1a3f0e4b1a7e7c4f8bd5f6c1e0d1a3e6b6f1b2d1c9a0e5
+
+%URL%
+https://fmnx.su/core/pack
+
+%LICENSE%
+GPL
+
+%ARCH%
+x86_64
+
+%BUILDDATE%
+1700000000
+
+%PACKAGER%
+John Doe <john@doe.com>
+
+%DEPENDS%
+pacman
+git
+
+%MAKEDEPENDS%
+go
+
+`
+
+var packEntry = Package{
+	Filename:      "pack-0.6.2-1-x86_64.pkg.tar.zst",
+	Name:          "pack",
+	Base:          "pack",
+	Version:       "0.6.2-1",
+	Desc:          "Decentralized package manager",
+	URL:           "https://fmnx.su/core/pack",
+	Arch:          "x86_64",
+	Packager:      "John Doe <john@doe.com>",
+	BuildDate:     1700000000,
+	CompressSize:  2451022,
+	InstalledSize: 7897088,
+	SHA256Sum:     "0c2bb5a3e3e8cd4e2c1a3f0e4b1a7e7c4f8bd5f6c1e0d1a3e6b6f1b2d1c9a0e5",
+	Licenses:      []string{"GPL"},
+	Depends:       []string{"pacman", "git"},
+	MakeDepends:   []string{"go"},
+}
+
+// Create database tarball with provided desc files, compressed with
+// provided function.
+func database(t *testing.T, compress func(io.Writer) io.WriteCloser, descs map[string]string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w := compress(&b)
+	tw := tar.NewWriter(w)
+	for dir, desc := range descs {
+		err := tw.WriteHeader(&tar.Header{Name: dir + "/", Typeflag: tar.TypeDir, Mode: 0755})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = tw.WriteHeader(&tar.Header{Name: dir + "/desc", Mode: 0644, Size: int64(len(desc))})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = tw.Write([]byte(desc))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := tw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+func TestRead(t *testing.T) {
+	compressions := []struct {
+		name     string
+		compress func(io.Writer) io.WriteCloser
+	}{
+		{"gzip", func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }},
+		{"zstd", func(w io.Writer) io.WriteCloser {
+			zw, err := zstd.NewWriter(w)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return zw
+		}},
+		{"plain", func(w io.Writer) io.WriteCloser { return nopCloser{w} }},
+	}
+	for _, c := range compressions {
+		db := database(t, c.compress, map[string]string{"pack-0.6.2-1": packDesc})
+		pkgs, err := Read(bytes.NewReader(db))
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if !reflect.DeepEqual(pkgs, []Package{packEntry}) {
+			t.Errorf("%s:\n  got:  %+v\n  want: %+v", c.name, pkgs, packEntry)
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	plain := func(w io.Writer) io.WriteCloser { return nopCloser{w} }
+	cases := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{
+			name: "bad number",
+			data: database(t, plain, map[string]string{"a-1-1": "%NAME%\na\n\n%CSIZE%\nbig\n"}),
+			err:  "unable to parse a-1-1/desc",
+		},
+		{
+			name: "truncated gzip",
+			data: database(t, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+				map[string]string{"pack-0.6.2-1": packDesc})[:20],
+			err: "unexpected EOF",
+		},
+	}
+	for _, c := range cases {
+		_, err := Read(bytes.NewReader(c.data))
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: expected error %q, got %v", c.name, c.err, err)
+		}
+	}
+}
+
+func TestSigner(t *testing.T) {
+	e, err := openpgp.NewEntity("John Doe", "", "john@doe.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sig bytes.Buffer
+	err = openpgp.DetachSign(&sig, e, strings.NewReader("package"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fpr := strings.ToUpper(hex.EncodeToString(e.PrimaryKey.Fingerprint))
+
+	cases := []struct {
+		name   string
+		pgpsig string
+		want   string
+		err    bool
+	}{
+		{name: "not signed", pgpsig: ``, want: ``},
+		{name: "fingerprint", pgpsig: base64.StdEncoding.EncodeToString(sig.Bytes()), want: fpr},
+		{name: "key id only", pgpsig: base64.StdEncoding.EncodeToString(keyIDSignature()), want: "0123456789ABCDEF"},
+		{name: "not base64", pgpsig: "!!!", err: true},
+		{name: "truncated", pgpsig: base64.StdEncoding.EncodeToString(sig.Bytes()[:10]), err: true},
+		{name: "not signature", pgpsig: base64.StdEncoding.EncodeToString(publicKey(t, e)), err: true},
+	}
+	for _, c := range cases {
+		got, err := Package{PGPSig: c.pgpsig}.Signer()
+		switch {
+		case c.err && err == nil:
+			t.Errorf("%s: expected error, got %q", c.name, got)
+		case !c.err && err != nil:
+			t.Errorf("%s: %v", c.name, err)
+		case got != c.want:
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func publicKey(t *testing.T, e *openpgp.Entity) []byte {
+	var b bytes.Buffer
+	err := e.PrimaryKey.Serialize(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// Version 4 EdDSA signature packet with issuer key ID subpacket, but without
+// issuer fingerprint, as made by older versions of GnuPG.
+func keyIDSignature() []byte {
+	hashed := []byte{5, 2, 0x65, 0x53, 0xf1, 0x00}
+	unhashed := []byte{9, 16, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+	body := []byte{4, 0x00, 22, 8, 0, byte(len(hashed))}
+	body = append(body, hashed...)
+	body = append(body, 0, byte(len(unhashed)))
+	body = append(body, unhashed...)
+	body = append(body, 0xab, 0xcd)
+	for i := 0; i < 2; i++ {
+		body = append(body, 0x00, 0x08, 0x01)
+	}
+	return append([]byte{0xc2, byte(len(body))}, body...)
+}
@@ -6,6 +6,7 @@
 package pacman
 
 import (
+	"context"
 	"io"
 	"os"
 	"strings"
@@ -50,8 +51,20 @@ func Upgrade(files string, opts ...UpgradeParameters) error {
 	return UpgradeList(strings.Split(files, " "), opts...)
 }
 
+// UpgradeContext is like Upgrade, but command is interrupted when provided
+// context is canceled.
+func UpgradeContext(ctx context.Context, files string, opts ...UpgradeParameters) error {
+	return UpgradeListContext(ctx, strings.Split(files, " "), opts...)
+}
+
 // Install packages from files.
 func UpgradeList(files []string, opts ...UpgradeParameters) error {
+	return UpgradeListContext(context.Background(), files, opts...)
+}
+
+// UpgradeListContext is like UpgradeList, but command is interrupted when
+// provided context is canceled.
+func UpgradeListContext(ctx context.Context, files []string, opts ...UpgradeParameters) error {
 	o := formOptions(opts, UpgradeDefault)
 
 	args := []string{"-U"}
@@ -76,12 +89,12 @@ func UpgradeList(files []string, opts ...UpgradeParameters) error {
 	args = append(args, o.AdditionalParams...)
 	args = append(args, files...)
 
-	cmd := sudoCommand(o.Sudo, pacman, args...)
+	cmd := sudoCommand(ctx, o.Sudo, pacman, args...)
 	cmd.Stdout = o.Stdout
 	cmd.Stderr = o.Stderr
 	cmd.Stdin = o.Stdin
 
 	mu.Lock()
 	defer mu.Unlock()
-	return cmd.Run()
+	return run(cmd)
 }
@@ -0,0 +1,29 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pacman
+
+import "testing"
+
+func TestUpgradeList(t *testing.T) {
+	f := fake(t)
+
+	err := UpgradeList([]string{"a.pkg.tar.zst", "b.pkg.tar.zst"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = UpgradeList([]string{"a.pkg.tar.zst"}, UpgradeParameters{
+		Sudo:        true,
+		NoScriptlet: true,
+		AsDeps:      true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCommands(t, f,
+		"pacman -U --needed --noconfirm a.pkg.tar.zst b.pkg.tar.zst",
+		"sudo pacman -U --noscriptlet --asdeps a.pkg.tar.zst",
+	)
+}
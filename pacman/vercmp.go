@@ -0,0 +1,148 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pacman
+
+import "strings"
+
+// Compare package versions in epoch:pkgver-pkgrel format the same way as
+// pacman does (alpm_pkg_vercmp). Returns -1 if a is older than b, 0 if
+// versions are equal and 1 if a is newer than b.
+func Vercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+	epoch1, ver1, rel1, hasrel1 := parseEVR(a)
+	epoch2, ver2, rel2, hasrel2 := parseEVR(b)
+
+	rez := rpmvercmp(epoch1, epoch2)
+	if rez != 0 {
+		return rez
+	}
+	rez = rpmvercmp(ver1, ver2)
+	if rez == 0 && hasrel1 && hasrel2 {
+		rez = rpmvercmp(rel1, rel2)
+	}
+	return rez
+}
+
+// Split version string into epoch, version and release. Epoch defaults to
+// 0, release is reported as missing only if version has no dash, so that
+// empty release ("1.0-") is compared like in libalpm.
+func parseEVR(evr string) (string, string, string, bool) {
+	epoch := "0"
+	version := evr
+
+	i := 0
+	for i < len(evr) && isDigit(evr[i]) {
+		i++
+	}
+	if i < len(evr) && evr[i] == ':' {
+		if i > 0 {
+			epoch = evr[:i]
+		}
+		version = evr[i+1:]
+	}
+
+	j := strings.LastIndexByte(version, '-')
+	if j < 0 {
+		return epoch, version, ``, false
+	}
+	return epoch, version[:j], version[j+1:], true
+}
+
+// Compare two version segments using rpm algorithm. Versions are split into
+// alternating blocks of digits and letters, that are compared one by one,
+// separators are ignored except for their length.
+func rpmvercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	var one, two int
+	for one < len(a) && two < len(b) {
+		ptr1, ptr2 := one, two
+		for one < len(a) && !isAlnum(a[one]) {
+			one++
+		}
+		for two < len(b) && !isAlnum(b[two]) {
+			two++
+		}
+		if one == len(a) || two == len(b) {
+			break
+		}
+
+		// Separators of different length, longer one wins.
+		if one-ptr1 != two-ptr2 {
+			if one-ptr1 < two-ptr2 {
+				return -1
+			}
+			return 1
+		}
+
+		ptr1, ptr2 = one, two
+		isnum := isDigit(a[ptr1])
+		class := isAlpha
+		if isnum {
+			class = isDigit
+		}
+		for ptr1 < len(a) && class(a[ptr1]) {
+			ptr1++
+		}
+		for ptr2 < len(b) && class(b[ptr2]) {
+			ptr2++
+		}
+
+		// Segment of b is of different type, numeric segment is newer than
+		// alphabetic.
+		if two == ptr2 {
+			if isnum {
+				return 1
+			}
+			return -1
+		}
+
+		seg1, seg2 := a[one:ptr1], b[two:ptr2]
+		if isnum {
+			seg1 = strings.TrimLeft(seg1, "0")
+			seg2 = strings.TrimLeft(seg2, "0")
+			if len(seg1) > len(seg2) {
+				return 1
+			}
+			if len(seg1) < len(seg2) {
+				return -1
+			}
+		}
+		rez := strings.Compare(seg1, seg2)
+		if rez != 0 {
+			return rez
+		}
+
+		one, two = ptr1, ptr2
+	}
+
+	if one == len(a) && two == len(b) {
+		return 0
+	}
+
+	// Remaining alpha string should never beat an empty string, so 1.0a is
+	// older than 1.0, while 1.0.1 is newer.
+	if (one == len(a) && !isAlpha(b[two])) || (one < len(a) && isAlpha(a[one])) {
+		return -1
+	}
+	return 1
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isAlnum(c byte) bool {
+	return isDigit(c) || isAlpha(c)
+}
@@ -0,0 +1,91 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pacman
+
+import "testing"
+
+// Cases from libalpm test/util/vercmptest.sh, each case is also checked in
+// reverse order.
+func TestVercmp(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		// all similar length, no pkgrel
+		{"1.5.0", "1.5.0", 0},
+		{"1.5.1", "1.5.0", 1},
+		// mixed length
+		{"1.5.1", "1.5", 1},
+		{"1.0.1", "1.0", 1},
+		// with pkgrel, simple
+		{"1.5.0-1", "1.5.0-1", 0},
+		{"1.5.0-1", "1.5.0-2", -1},
+		{"1.5.0-1", "1.5.1-1", -1},
+		{"1.5.0-2", "1.5.1-1", -1},
+		// with pkgrel, mixed lengths
+		{"1.5-1", "1.5.1-1", -1},
+		{"1.5-2", "1.5.1-1", -1},
+		{"1.5-2", "1.5.1-2", -1},
+		// mixed pkgrel inclusion
+		{"1.5", "1.5-1", 0},
+		{"1.5-1", "1.5", 0},
+		{"1.1-1", "1.1", 0},
+		{"1.0-1", "1.1", -1},
+		{"1.1-1", "1.0", 1},
+		// empty pkgrel is present, but older than any release
+		{"1.0-", "1.0", 0},
+		{"1.0-", "1.0-1", -1},
+		{"1.0-", "1.0-", 0},
+		// alphanumeric versions
+		{"1.5b-1", "1.5-1", -1},
+		{"1.5b", "1.5", -1},
+		{"1.5b-1", "1.5", -1},
+		{"1.5b", "1.5.1", -1},
+		{"1.0a", "1.0", -1},
+		// from the manpage
+		{"1.0a", "1.0alpha", -1},
+		{"1.0alpha", "1.0b", -1},
+		{"1.0b", "1.0beta", -1},
+		{"1.0beta", "1.0rc", -1},
+		{"1.0rc", "1.0", -1},
+		// alpha-dotted versions
+		{"1.5.a", "1.5", 1},
+		{"1.5.b", "1.5.a", 1},
+		{"1.5.1", "1.5.b", 1},
+		// alpha dots and dashes
+		{"1.5.b-1", "1.5.b", 0},
+		{"1.5-1", "1.5.b", -1},
+		// same/similar content, differing separators
+		{"2.0", "2_0", 0},
+		{"2.0_a", "2_0.a", 0},
+		{"2.0a", "2.0.a", -1},
+		{"2___a", "2_a", 1},
+		// epoch included version comparisons
+		{"0:1.0", "0:1.0", 0},
+		{"0:1.0", "0:1.1", -1},
+		{"1:1.0", "0:1.0", 1},
+		{"1:1.0", "0:1.1", 1},
+		{"1:1.0", "2:1.1", -1},
+		// epoch + sometimes present pkgrel
+		{"1:1.0", "0:1.0-1", 1},
+		{"1:1.0-1", "0:1.1-1", 1},
+		// epoch included on one version
+		{"0:1.0", "1.0", 0},
+		{"0:1.0", "1.1", -1},
+		{"0:1.1", "1.0", 1},
+		{"1:1.0", "1.0", 1},
+		{"1:1.0", "1.1", 1},
+		{"1:1.1", "1.1", 1},
+	}
+	for _, c := range cases {
+		if got := Vercmp(c.a, c.b); got != c.want {
+			t.Errorf("Vercmp(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+		if got := Vercmp(c.b, c.a); got != -c.want {
+			t.Errorf("Vercmp(%q, %q) = %d, want %d", c.b, c.a, got, -c.want)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pgp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+// Get GnuPG home directory, GNUPGHOME environment variable is respected.
+func GnuPGHome() (string, error) {
+	if home := os.Getenv("GNUPGHOME"); home != `` {
+		return home, nil
+	}
+	hd, err := os.UserHomeDir()
+	if err != nil {
+		return ``, err
+	}
+	return path.Join(hd, ".gnupg"), nil
+}
+
+// Load keys from GnuPG keyring. Legacy keyring files (pubring.gpg and
+// secring.gpg) are read directly, keybox keyrings require gpg to export keys.
+// With secret set to true only keys with private part are returned, and from
+// keybox only key with provided fingerprint (or first usable for signing if
+// fingerprint is empty) is exported, so that passphrase is not asked for
+// every key in keyring. Without secret and fingerprint only public key of
+// the first secret key usable for signing is returned.
+func GnuPG(secret bool, fingerprint string) ([]*Key, error) {
+	home, err := GnuPGHome()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(home); err != nil {
+		return nil, fmt.Errorf("GnuPG home not found: %s", home)
+	}
+
+	legacy := "pubring.gpg"
+	if secret {
+		legacy = "secring.gpg"
+	}
+	if fi, err := os.Stat(path.Join(home, legacy)); err == nil && fi.Size() > 0 {
+		keys, err := ReadFile(path.Join(home, legacy))
+		if err != nil {
+			return nil, err
+		}
+		if !secret && fingerprint == `` {
+			keys, err = filterOwn(keys, home)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return filterSecret(keys, secret, home)
+	}
+
+	args := []string{"--export"}
+	if secret {
+		secrets, err := listSecret(home)
+		if err != nil {
+			return nil, err
+		}
+		fpr, err := selectSecret(secrets, fingerprint)
+		if err != nil {
+			return nil, err
+		}
+		args = []string{"--export-secret-keys", fpr}
+	}
+	b, err := gpg(home, args...)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := Read(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse keys exported from GnuPG: %w", err)
+	}
+	if !secret && fingerprint == `` {
+		keys, err = filterOwn(keys, home)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return filterSecret(keys, secret, home)
+}
+
+// Leave only public key of user, which is the first key with secret part
+// usable for signing. Public keyring also contains imported keys of other
+// people, like registry owners, that can't be used to sign packages.
+func filterOwn(keys []*Key, home string) ([]*Key, error) {
+	var fpr string
+	secring := path.Join(home, "secring.gpg")
+	if fi, err := os.Stat(secring); err == nil && fi.Size() > 0 {
+		secrets, err := ReadFile(secring)
+		if err != nil {
+			return nil, err
+		}
+		own, err := Select(secrets, ``)
+		if err != nil {
+			return nil, err
+		}
+		fpr = own.Fingerprint()
+	} else {
+		secrets, err := listSecret(home)
+		if err != nil {
+			return nil, err
+		}
+		fpr, err = selectSecret(secrets, ``)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, k := range keys {
+		if k.Fingerprint() == fpr {
+			return []*Key{k}, nil
+		}
+	}
+	return nil, fmt.Errorf("public key %s not found in GnuPG home %s", fpr, home)
+}
+
+func filterSecret(keys []*Key, secret bool, home string) ([]*Key, error) {
+	var rez []*Key
+	for _, k := range keys {
+		if !secret || k.Secret() {
+			rez = append(rez, k)
+		}
+	}
+	if len(rez) == 0 {
+		kind := "public"
+		if secret {
+			kind = "secret"
+		}
+		return nil, fmt.Errorf("no %s keys found in GnuPG home %s", kind, home)
+	}
+	return rez, nil
+}
+
+// Get fingerprints of keys, that have private part in GnuPG keyring,
+// without exporting them.
+func GnuPGSecretFingerprints() ([]string, error) {
+	home, err := GnuPGHome()
+	if err != nil {
+		return nil, err
+	}
+	secrets, err := listSecret(home)
+	if err != nil {
+		return nil, err
+	}
+	var fprs []string
+	for _, s := range secrets {
+		fprs = append(fprs, s.fpr)
+	}
+	return fprs, nil
+}
+
+// Secret key listed by gpg --list-secret-keys.
+type gnupgSecret struct {
+	fpr  string
+	uid  string
+	sign bool
+}
+
+// List secret keys in GnuPG keyring with their primary key fingerprints.
+func listSecret(home string) ([]gnupgSecret, error) {
+	b, err := gpg(home, "--list-secret-keys", "--with-colons")
+	if err != nil {
+		return nil, err
+	}
+	var rez []gnupgSecret
+	var cur *gnupgSecret
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Split(line, ":")
+		switch {
+		case fields[0] == "sec":
+			rez = append(rez, gnupgSecret{})
+			cur = &rez[len(rez)-1]
+			// Expired, revoked or disabled keys have no usable capabilities.
+			cur.sign = len(fields) > 11 && strings.Contains(fields[11], "S") &&
+				!strings.ContainsAny(fields[1], "erd")
+		case fields[0] == "fpr" && cur != nil && cur.fpr == `` && len(fields) > 9:
+			cur.fpr = fields[9]
+		case fields[0] == "uid" && cur != nil && cur.uid == `` && len(fields) > 9:
+			cur.uid = fields[9]
+		}
+	}
+	return rez, nil
+}
+
+// Select fingerprint of secret key by fingerprint or it's suffix, first key
+// usable for signing is selected if fingerprint is empty.
+func selectSecret(secrets []gnupgSecret, fingerprint string) (string, error) {
+	fingerprint = strings.ToUpper(strings.ReplaceAll(fingerprint, " ", ""))
+	var found []string
+	for _, s := range secrets {
+		switch {
+		case fingerprint == `` && s.sign:
+			return s.fpr, nil
+		case fingerprint != `` && strings.HasSuffix(s.fpr, fingerprint):
+			found = append(found, s.fpr)
+		}
+	}
+	switch {
+	case len(secrets) == 0:
+		return ``, errors.New("no secret keys found in GnuPG keyring")
+	case fingerprint == ``:
+		return ``, errors.New("no valid signing keys found in GnuPG keyring")
+	case len(found) == 1:
+		return found[0], nil
+	case len(found) > 1:
+		return ``, fmt.Errorf("key %s is ambiguous, provide full fingerprint", fingerprint)
+	}
+	var avail []string
+	for _, s := range secrets {
+		avail = append(avail, s.fpr+" "+s.uid)
+	}
+	return ``, fmt.Errorf(
+		"key %s not found, available keys:\n%s",
+		fingerprint, strings.Join(avail, "\n"),
+	)
+}
+
+func gpg(home string, args ...string) ([]byte, error) {
+	_, err := exec.LookPath("gpg")
+	if err != nil {
+		return nil, errors.New("gpg is not installed, provide exported key file instead")
+	}
+	var b, errb bytes.Buffer
+	cmd := exec.Command("gpg", append([]string{"--homedir", home}, args...)...)
+	cmd.Stdout = &b
+	cmd.Stderr = &errb
+	cmd.Stdin = os.Stdin
+	err = cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("gpg %s failed: %s", args[0], strings.TrimSpace(errb.String()))
+	}
+	return b.Bytes(), nil
+}
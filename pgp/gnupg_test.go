@@ -0,0 +1,133 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pgp
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// Create GnuPG home and generate key with provided email in it.
+func gnupgHome(t *testing.T, email string) (string, string) {
+	t.Helper()
+	home, err := os.MkdirTemp(``, "pack-gpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		exec.Command("gpgconf", "--homedir", home, "--kill", "gpg-agent").Run()
+		os.RemoveAll(home)
+	})
+	run(t, home, nil, "--passphrase", ``, "--quick-gen-key", "Test <"+email+">", "ed25519", "sign", "never")
+	secrets, err := listSecret(home)
+	if err != nil || len(secrets) != 1 {
+		t.Fatalf("unable to list generated key: %v", err)
+	}
+	return home, secrets[0].fpr
+}
+
+func run(t *testing.T, home string, stdin []byte, args ...string) []byte {
+	t.Helper()
+	args = append([]string{"--homedir", home, "--batch", "--pinentry-mode", "loopback"}, args...)
+	cmd := exec.Command("gpg", args...)
+	if stdin != nil {
+		cmd.Stdin = strings.NewReader(string(stdin))
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("gpg %s: %v", strings.Join(args, " "), err)
+	}
+	return out
+}
+
+// Public keys imported to keyring (for example keys of registry owners)
+// should not be used as packager key.
+func TestGnuPGOwnKey(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg is not installed")
+	}
+	other, otherfpr := gnupgHome(t, "owner@registry.org")
+	pub := run(t, other, nil, "--export", otherfpr)
+
+	home, err := os.MkdirTemp(``, "pack-gpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		exec.Command("gpgconf", "--homedir", home, "--kill", "gpg-agent").Run()
+		os.RemoveAll(home)
+	})
+	// Imported key goes first in keyring.
+	run(t, home, pub, "--import")
+	run(t, home, nil, "--passphrase", ``, "--quick-gen-key", "Own <own@doe.com>", "ed25519", "sign", "never")
+	t.Setenv("GNUPGHOME", home)
+
+	keys, err := GnuPG(false, ``)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := Select(keys, ``)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Email() != "own@doe.com" {
+		t.Errorf("expected own key, got %s", key.Identity())
+	}
+
+	keys, err = GnuPG(false, otherfpr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err = Select(keys, otherfpr)
+	if err != nil || key.Fingerprint() != otherfpr {
+		t.Errorf("key selected by fingerprint should be found: %v", err)
+	}
+
+	keys, err = GnuPG(true, ``)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].Email() != "own@doe.com" || !keys[0].Secret() {
+		t.Errorf("expected own secret key, got %d keys", len(keys))
+	}
+}
+
+func TestSelectSecret(t *testing.T) {
+	secrets := []gnupgSecret{
+		{fpr: "AAAA1111", uid: "Expired <old@doe.com>"},
+		{fpr: "BBBB2222", uid: "John <john@doe.com>", sign: true},
+		{fpr: "CCCC2222", uid: "Jane <jane@doe.com>", sign: true},
+	}
+	cases := []struct {
+		secrets     []gnupgSecret
+		fingerprint string
+		want        string
+		err         string
+	}{
+		{secrets, ``, "BBBB2222", ``},
+		{secrets, "cccc 2222", "CCCC2222", ``},
+		{secrets, "1111", "AAAA1111", ``},
+		{secrets, "2222", ``, "ambiguous"},
+		{secrets, "DDDD", ``, "available keys"},
+		{secrets[:1], ``, ``, "no valid signing keys"},
+		{nil, ``, ``, "no secret keys"},
+	}
+	for _, c := range cases {
+		got, err := selectSecret(c.secrets, c.fingerprint)
+		switch {
+		case c.err != ``:
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%q: expected error %q, got %v", c.fingerprint, c.err, err)
+			}
+		case err != nil:
+			t.Errorf("%q: %v", c.fingerprint, err)
+		case got != c.want:
+			t.Errorf("%q: got %s, want %s", c.fingerprint, got, c.want)
+		}
+	}
+}
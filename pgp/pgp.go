@@ -0,0 +1,219 @@
+// 2023 FMNX team.
+// Use of this code is governed by GNU General Public License.
+// Official web page: https://fmnx.su/core/pack
+// Contact email: help@fmnx.su
+
+package pgp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// OpenPGP key, that can be used to sign packages and requests.
+type Key struct {
+	entity *openpgp.Entity
+}
+
+// Read keys from armored or binary key file, exported with gpg --export or
+// gpg --export-secret-keys.
+func ReadFile(file string) ([]*Key, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read key file: %w", err)
+	}
+	keys, err := Read(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse key file %s: %w", file, err)
+	}
+	return keys, nil
+}
+
+// Read keys from armored or binary keyring.
+func Read(r io.Reader) ([]*Key, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(5)
+
+	var el openpgp.EntityList
+	var err error
+	if string(head) == "-----" {
+		var block *armor.Block
+		block, err = armor.Decode(br)
+		if err != nil {
+			return nil, err
+		}
+		el, err = openpgp.ReadKeyRing(block.Body)
+	} else {
+		el, err = openpgp.ReadKeyRing(br)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*Key
+	for _, e := range el {
+		keys = append(keys, &Key{entity: e})
+	}
+	return keys, nil
+}
+
+// Select key by fingerprint (or it's suffix, like long key ID). If
+// fingerprint is empty, first key usable for signing is returned.
+func Select(keys []*Key, fingerprint string) (*Key, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no keys found")
+	}
+	fingerprint = strings.ToUpper(strings.ReplaceAll(fingerprint, " ", ""))
+	if fingerprint == `` {
+		for _, k := range keys {
+			if k.Validate() == nil {
+				return k, nil
+			}
+		}
+		return nil, errors.Join(
+			errors.New("no valid signing keys found"),
+			keys[0].Validate(),
+		)
+	}
+
+	var found []*Key
+	for _, k := range keys {
+		if strings.HasSuffix(k.Fingerprint(), fingerprint) {
+			found = append(found, k)
+		}
+	}
+	switch len(found) {
+	case 0:
+		var avail []string
+		for _, k := range keys {
+			avail = append(avail, k.Fingerprint()+" "+k.Identity())
+		}
+		return nil, fmt.Errorf(
+			"key %s not found, available keys:\n%s",
+			fingerprint, strings.Join(avail, "\n"),
+		)
+	case 1:
+		return found[0], found[0].Validate()
+	}
+	return nil, fmt.Errorf("key %s is ambiguous, provide full fingerprint", fingerprint)
+}
+
+// Full fingerprint of primary key in upper case hex.
+func (k *Key) Fingerprint() string {
+	return strings.ToUpper(hex.EncodeToString(k.entity.PrimaryKey.Fingerprint))
+}
+
+// Primary identity of key, formatted as 'Name <email>'.
+func (k *Key) Identity() string {
+	id := k.entity.PrimaryIdentity()
+	if id == nil {
+		return ``
+	}
+	return id.Name
+}
+
+// All identities of key.
+func (k *Key) Identities() []string {
+	var ids []string
+	for name := range k.entity.Identities {
+		ids = append(ids, name)
+	}
+	return ids
+}
+
+// Email from primary identity of key.
+func (k *Key) Email() string {
+	id := k.entity.PrimaryIdentity()
+	if id == nil {
+		return ``
+	}
+	if id.UserId.Email != `` {
+		return id.UserId.Email
+	}
+	addr, err := mail.ParseAddress(id.Name)
+	if err != nil {
+		return ``
+	}
+	return addr.Address
+}
+
+// Check wether key contains private part.
+func (k *Key) Secret() bool {
+	return k.entity.PrivateKey != nil
+}
+
+// Check wether private key is protected with passphrase.
+func (k *Key) Encrypted() bool {
+	if k.entity.PrivateKey != nil && k.entity.PrivateKey.Encrypted {
+		return true
+	}
+	for _, sk := range k.entity.Subkeys {
+		if sk.PrivateKey != nil && sk.PrivateKey.Encrypted {
+			return true
+		}
+	}
+	return false
+}
+
+// Decrypt private key with provided passphrase.
+func (k *Key) Unlock(passphrase []byte) error {
+	err := k.entity.DecryptPrivateKeys(passphrase)
+	if err != nil {
+		return fmt.Errorf("unable to unlock key %s: %w", k.Fingerprint(), err)
+	}
+	return nil
+}
+
+// Ensure that key is not revoked, not expired and can be used for signing.
+func (k *Key) Validate() error {
+	now := time.Now()
+	if k.entity.Revoked(now) {
+		return fmt.Errorf("key %s is revoked", k.Fingerprint())
+	}
+	_, sig := k.entity.PrimarySelfSignature()
+	if sig != nil && k.entity.PrimaryKey.KeyExpired(sig.SelfSignature, now) {
+		return fmt.Errorf("key %s is expired", k.Fingerprint())
+	}
+	if _, ok := k.entity.SigningKey(now); !ok {
+		return fmt.Errorf("key %s has no valid signing key", k.Fingerprint())
+	}
+	return nil
+}
+
+// Create binary detached signature for provided message.
+func (k *Key) DetachSign(msg io.Reader) ([]byte, error) {
+	if !k.Secret() {
+		return nil, fmt.Errorf("private key %s is not available", k.Fingerprint())
+	}
+	if k.Encrypted() {
+		return nil, fmt.Errorf("private key %s is locked with passphrase", k.Fingerprint())
+	}
+	var b bytes.Buffer
+	err := openpgp.DetachSign(&b, k.entity, msg, &packet.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign with key %s: %w", k.Fingerprint(), err)
+	}
+	return b.Bytes(), nil
+}
+
+// Export public key in armored format.
+func (k *Key) Armor(w io.Writer) error {
+	aw, err := armor.Encode(w, openpgp.PublicKeyType, nil)
+	if err != nil {
+		return err
+	}
+	err = k.entity.Serialize(aw)
+	return errors.Join(err, aw.Close())
+}